@@ -62,7 +62,16 @@ func main() {
 		},
 	})
 
-	// 6. 模拟批量日志
+	// 6. 强类型字段，不需要创建map
+	client.InfoTyped("订单创建",
+		elk.String("order_id", "ORD-1001"),
+		elk.Int("items", 3),
+		elk.Duration("elapsed", 35*time.Millisecond),
+		elk.Bool("paid", true),
+		elk.String("channel", "app"),
+	)
+
+	// 7. 模拟批量日志
 	fmt.Println("发送批量日志...")
 	for i := 0; i < 50; i++ {
 		client.Info(fmt.Sprintf("批量日志 #%d", i), elk.Fields{
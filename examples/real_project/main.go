@@ -51,8 +51,8 @@ func main() {
 	http.HandleFunc("/api/users/create", createUserHandler)
 	http.HandleFunc("/health", healthHandler)
 
-	// ========== 第3步：启动定时任务（展示后台任务日志） ==========
-	go backgroundTask()
+	// ========== 第3步：启动定时任务（展示后台任务日志，panic会被记录到ES） ==========
+	elkLogger.Go(backgroundTask)
 
 	// ========== 第4步：启动HTTP服务器 ==========
 	fmt.Println("========================================")
@@ -63,10 +63,11 @@ func main() {
 	fmt.Println("========================================")
 
 	if err := http.ListenAndServe(":8080", nil); err != nil {
-		elkLogger.Fatal("服务启动失败", elk.Fields{
+		// 同步发送后刷新所有日志再退出，避免最重要的日志丢失
+		log.Println(err)
+		elkLogger.FatalAndExit("服务启动失败", elk.Fields{
 			"error": err.Error(),
 		})
-		log.Fatal(err)
 	}
 }
 
@@ -0,0 +1,127 @@
+package elk_logger
+
+import (
+	"sync"
+	"time"
+)
+
+// AdaptiveController 自适应批量控制器
+// 根据批量请求延迟、队列积压和集群拒绝情况动态调整批量大小与超时
+type AdaptiveController struct {
+	mu      sync.Mutex
+	config  *Config
+	batch   *Batch
+	metrics *Metrics
+
+	size    int
+	timeout time.Duration
+	backoff time.Duration
+}
+
+// NewAdaptiveController 创建新的自适应批量控制器
+func NewAdaptiveController(config *Config, batch *Batch, metrics *Metrics) *AdaptiveController {
+	a := &AdaptiveController{
+		config:  config,
+		batch:   batch,
+		metrics: metrics,
+		size:    config.BatchSize,
+		timeout: config.BatchTimeout,
+	}
+	a.apply()
+	return a
+}
+
+// Observe 根据一次批量发送的结果调整参数
+// latency为发送耗时，queueDepth为发送完成时的队列积压数
+func (a *AdaptiveController) Observe(latency time.Duration, queueDepth int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case IsRejected(err):
+		// 集群拒绝执行：减半批量并退避
+		a.shrink()
+		if a.backoff == 0 {
+			a.backoff = a.config.RetryInterval
+		} else {
+			a.backoff *= 2
+		}
+		if a.backoff > a.config.MaxRetryBackoff {
+			a.backoff = a.config.MaxRetryBackoff
+		}
+	case err != nil:
+		// 网络等其他错误不作为调整依据
+		return
+	case latency > a.config.TargetLatency:
+		// 延迟上升：减小批量
+		a.shrink()
+		a.backoff = 0
+	case queueDepth >= a.size:
+		// 延迟正常且积压较多：增大批量、缩短超时
+		a.size += a.size/4 + 1
+		if a.size > a.config.MaxBatchSize {
+			a.size = a.config.MaxBatchSize
+		}
+		a.timeout /= 2
+		if a.timeout < a.config.MinBatchTimeout {
+			a.timeout = a.config.MinBatchTimeout
+		}
+		a.backoff = 0
+	case queueDepth == 0:
+		// 负载较低：延长超时，减少小批量请求
+		a.timeout += a.timeout / 2
+		if a.timeout > a.config.MaxBatchTimeout {
+			a.timeout = a.config.MaxBatchTimeout
+		}
+		a.backoff = 0
+	default:
+		a.backoff = 0
+	}
+
+	a.apply()
+}
+
+// reset 使用新配置重新开始调整
+func (a *AdaptiveController) reset(config *Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.config = config
+	a.size = config.BatchSize
+	a.timeout = config.BatchTimeout
+	a.backoff = 0
+	a.apply()
+}
+
+// Backoff 返回下一次发送前应等待的退避时间
+func (a *AdaptiveController) Backoff() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.backoff
+}
+
+// Current 返回当前批量大小和超时
+func (a *AdaptiveController) Current() (int, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size, a.timeout
+}
+
+// shrink 减半批量大小并延长超时
+func (a *AdaptiveController) shrink() {
+	a.size /= 2
+	if a.size < a.config.MinBatchSize {
+		a.size = a.config.MinBatchSize
+	}
+	a.timeout *= 2
+	if a.timeout > a.config.MaxBatchTimeout {
+		a.timeout = a.config.MaxBatchTimeout
+	}
+}
+
+// apply 将当前参数应用到批次并更新指标
+func (a *AdaptiveController) apply() {
+	a.batch.SetMaxSize(a.size)
+	a.batch.SetTimeout(a.timeout)
+	a.metrics.SetBatchParams(a.size, a.timeout)
+}
@@ -0,0 +1,95 @@
+package elk_logger
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// credentials ES认证信息
+type credentials struct {
+	username     string
+	password     string
+	apiKey       string
+	serviceToken string
+}
+
+// loadCredentials 读取认证信息，*File配置项指向的文件在此时读取
+func loadCredentials(config *Config) (credentials, error) {
+	creds := credentials{
+		username:     config.ESUsername,
+		password:     config.ESPassword,
+		apiKey:       config.ESAPIKey,
+		serviceToken: config.ESServiceToken,
+	}
+
+	secrets := []struct {
+		path string
+		dst  *string
+	}{
+		{config.ESPasswordFile, &creds.password},
+		{config.ESAPIKeyFile, &creds.apiKey},
+		{config.ESServiceTokenFile, &creds.serviceToken},
+	}
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+		value, err := readSecret(secret.path)
+		if err != nil {
+			return credentials{}, err
+		}
+		*secret.dst = value
+	}
+
+	return creds, nil
+}
+
+// readSecret 读取密钥文件，去掉首尾空白（如文件末尾的换行）
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return value, nil
+}
+
+// newTLSConfig 按配置创建TLS配置，未配置任何TLS选项时返回nil
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	if config.ESCACert == "" && config.ESClientCert == "" && !config.ESInsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.ESInsecureSkipVerify,
+	}
+
+	if config.ESCACert != "" {
+		pem, err := os.ReadFile(config.ESCACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in %s", config.ESCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ESClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.ESClientCert, config.ESClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
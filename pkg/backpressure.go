@@ -0,0 +1,145 @@
+package elk_logger
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrQueueFull 队列满导致日志被丢弃
+var ErrQueueFull = errors.New("queue is full, log dropped")
+
+// QueueFullPolicy 队列满时的处理策略
+type QueueFullPolicy string
+
+const (
+	PolicyBlock          QueueFullPolicy = "block"            // 一直阻塞直到入队
+	PolicyBlockTimeout   QueueFullPolicy = "block_timeout"    // 阻塞等待，超时后丢弃
+	PolicyDropNewest     QueueFullPolicy = "drop_newest"      // 丢弃新日志
+	PolicyDropOldest     QueueFullPolicy = "drop_oldest"      // 丢弃同级或更低优先级通道中最旧的日志
+	PolicyDropByPriority QueueFullPolicy = "drop_by_priority" // 低级别日志直接丢弃，高级别日志阻塞等待
+)
+
+// valid 判断策略是否合法
+func (p QueueFullPolicy) valid() bool {
+	switch p {
+	case PolicyBlock, PolicyBlockTimeout, PolicyDropNewest, PolicyDropOldest, PolicyDropByPriority:
+		return true
+	default:
+		return false
+	}
+}
+
+// enqueue 按队列满策略将日志放入对应级别的通道
+// 被丢弃的日志条目会被回收
+func (c *Client) enqueue(entry *LogEntry) error {
+	queue := c.laneFor(entry)
+
+	select {
+	case queue <- entry:
+		return nil
+	default:
+	}
+
+	// 队列满
+	config := c.cfg()
+	switch config.queueFullPolicy() {
+	case PolicyBlock:
+		c.metrics.IncBlocked()
+		select {
+		case queue <- entry:
+			return nil
+		case <-c.ctx.Done():
+			c.metrics.IncDroppedTimeout()
+			releaseEntry(entry)
+			return ErrQueueFull
+		}
+
+	case PolicyDropNewest:
+		c.metrics.IncDroppedNewest()
+		releaseEntry(entry)
+		return nil
+
+	case PolicyDropOldest:
+		return c.enqueueDropOldest(entry)
+
+	case PolicyDropByPriority:
+		if entry.Level.Priority() < config.PriorityDropLevel.Priority() {
+			c.metrics.IncDroppedPriority()
+			releaseEntry(entry)
+			return nil
+		}
+		return c.enqueueWithTimeout(queue, entry)
+
+	default:
+		return c.enqueueWithTimeout(queue, entry)
+	}
+}
+
+// dropOldestAttempts 丢弃最旧日志后重新入队的尝试次数
+// 并发写入持续抢占腾出的位置时，超过次数后改为丢弃新日志，避免忙等
+const dropOldestAttempts = 3
+
+// enqueueDropOldest 丢弃最旧的日志为新日志腾出位置
+// 只使用日志所在通道及更低优先级的通道，优先占用空位，其次从最低优先级的通道开始丢弃；
+// 高级别日志可以进入低优先级通道，但不会被低级别日志挤掉
+func (c *Client) enqueueDropOldest(entry *LogEntry) error {
+	for attempt := 0; attempt < dropOldestAttempts; attempt++ {
+		own := laneOf(entry.Level)
+		for lane := own + 1; lane < laneCount; lane++ {
+			select {
+			case c.lanes[lane] <- entry:
+				return nil
+			default:
+			}
+		}
+
+		for lane := laneCount - 1; lane >= own; lane-- {
+			queue := c.lanes[lane]
+			var oldest *LogEntry
+			select {
+			case oldest = <-queue:
+			default:
+				continue
+			}
+
+			if laneOf(oldest.Level) < own {
+				// 取出的是更高级别的日志，保留它并丢弃新日志
+				c.metrics.IncDroppedNewest()
+				releaseEntry(entry)
+				entry, own = oldest, laneOf(oldest.Level)
+			} else {
+				c.metrics.IncDroppedOldest()
+				releaseEntry(oldest)
+			}
+
+			select {
+			case queue <- entry:
+				return nil
+			default:
+			}
+		}
+	}
+
+	c.metrics.IncDroppedNewest()
+	releaseEntry(entry)
+	return nil
+}
+
+// enqueueWithTimeout 阻塞等待入队，超过EnqueueTimeout后丢弃
+func (c *Client) enqueueWithTimeout(queue chan *LogEntry, entry *LogEntry) error {
+	c.metrics.IncBlocked()
+
+	timer := time.NewTimer(c.cfg().EnqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case queue <- entry:
+		return nil
+	case <-timer.C:
+	case <-c.ctx.Done():
+	}
+
+	c.metrics.IncDroppedTimeout()
+	releaseEntry(entry)
+	return ErrQueueFull
+}
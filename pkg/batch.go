@@ -6,10 +6,14 @@ import (
 )
 
 // Batch 批量管理器
+// 使用双缓冲：Flush交出当前切片并换上备用切片，发送完成后通过Recycle归还作为下一个备用切片
 type Batch struct {
 	mu        sync.Mutex
 	entries   []*LogEntry
+	spare     []*LogEntry // 备用切片，nil表示没有可复用的切片
 	maxSize   int
+	maxBytes  int
+	bytes     int
 	timeout   time.Duration
 	lastFlush time.Time
 }
@@ -24,6 +28,27 @@ func NewBatch(maxSize int, timeout time.Duration) *Batch {
 	}
 }
 
+// SetMaxBytes 设置批次最大字节数（0表示不限制）
+func (b *Batch) SetMaxBytes(maxBytes int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxBytes = maxBytes
+}
+
+// SetMaxSize 调整批次最大条数
+func (b *Batch) SetMaxSize(maxSize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxSize = maxSize
+}
+
+// SetTimeout 调整批次超时时间
+func (b *Batch) SetTimeout(timeout time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeout = timeout
+}
+
 // Add 添加日志条目
 // 返回值：是否需要刷新
 func (b *Batch) Add(entry *LogEntry) bool {
@@ -31,8 +56,12 @@ func (b *Batch) Add(entry *LogEntry) bool {
 	defer b.mu.Unlock()
 
 	b.entries = append(b.entries, entry)
+	b.bytes += entry.EstimateSize()
 
 	// 检查是否需要刷新
+	if b.maxBytes > 0 && b.bytes >= b.maxBytes {
+		return true
+	}
 	return len(b.entries) >= b.maxSize
 }
 
@@ -59,12 +88,35 @@ func (b *Batch) Flush() []*LogEntry {
 
 	// 交换缓冲区
 	entries := b.entries
-	b.entries = make([]*LogEntry, 0, b.maxSize)
+	b.entries = b.takeSpare()
+	b.bytes = 0
 	b.lastFlush = time.Now()
 
 	return entries
 }
 
+// Recycle 归还Flush返回的切片，作为下次Flush的缓冲区
+// 调用后不能再使用entries；已有备用切片或容量不足时直接丢弃
+func (b *Batch) Recycle(entries []*LogEntry) {
+	clear(entries)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spare == nil && cap(entries) >= b.maxSize {
+		b.spare = entries[:0]
+	}
+}
+
+// takeSpare 取出备用切片，没有时新建，调用方需持有锁
+func (b *Batch) takeSpare() []*LogEntry {
+	if spare := b.spare; spare != nil && cap(spare) >= b.maxSize {
+		b.spare = nil
+		return spare
+	}
+	b.spare = nil
+	return make([]*LogEntry, 0, b.maxSize)
+}
+
 // Size 返回当前批次大小
 func (b *Batch) Size() int {
 	b.mu.Lock()
@@ -72,10 +124,19 @@ func (b *Batch) Size() int {
 	return len(b.entries)
 }
 
+// Bytes 返回当前批次的估算字节数
+func (b *Batch) Bytes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
 // Clear 清空批次
 func (b *Batch) Clear() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.entries = make([]*LogEntry, 0, b.maxSize)
+	clear(b.entries)
+	b.entries = b.entries[:0]
+	b.bytes = 0
 	b.lastFlush = time.Now()
 }
@@ -0,0 +1,130 @@
+package elk_logger
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断器打开时返回的错误
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // 关闭：正常发送
+	CircuitOpen                         // 打开：直接失败
+	CircuitHalfOpen                     // 半开：允许一次探测
+)
+
+// String 返回状态名称
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker 发送熔断器
+// 连续失败达到阈值后打开，经过openTimeout后进入半开状态放行一次探测请求
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(from, to CircuitState)
+
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker 创建新的熔断器
+// onChange在状态变化时调用，可以为nil
+func NewCircuitBreaker(threshold int, openTimeout time.Duration, onChange func(from, to CircuitState)) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		onChange:    onChange,
+		state:       CircuitClosed,
+	}
+}
+
+// Allow 判断是否允许发送
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			cb.mu.Unlock()
+			return false
+		}
+		// 超过打开时间，放行一次探测
+		cb.probing = true
+		cb.transition(CircuitHalfOpen)
+		return true
+	case CircuitHalfOpen:
+		allowed := !cb.probing
+		cb.probing = true
+		cb.mu.Unlock()
+		return allowed
+	default:
+		cb.mu.Unlock()
+		return true
+	}
+}
+
+// RecordSuccess 记录一次发送成功
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+
+	cb.failures = 0
+	cb.probing = false
+	if cb.state == CircuitClosed {
+		cb.mu.Unlock()
+		return
+	}
+	cb.transition(CircuitClosed)
+}
+
+// RecordFailure 记录一次发送失败
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+
+	cb.failures++
+	cb.probing = false
+
+	// 半开状态探测失败立即重新打开
+	if cb.state == CircuitHalfOpen || (cb.state == CircuitClosed && cb.failures >= cb.threshold) {
+		cb.openedAt = time.Now()
+		cb.transition(CircuitOpen)
+		return
+	}
+	cb.mu.Unlock()
+}
+
+// State 返回当前状态
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// transition 切换状态并释放锁，回调在锁外执行
+func (cb *CircuitBreaker) transition(state CircuitState) {
+	from := cb.state
+	cb.state = state
+	cb.mu.Unlock()
+
+	if cb.onChange != nil {
+		cb.onChange(from, state)
+	}
+}
@@ -2,20 +2,28 @@ package elk_logger
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Client ELK日志客户端
 type Client struct {
-	config  *Config
-	sender  *Sender
-	batch   *Batch
-	queue   chan *LogEntry
-	metrics *Metrics
+	config   atomic.Pointer[Config] // 当前配置，热更新时整体替换
+	batch    *Batch
+	lanes    [laneCount]chan *LogEntry
+	metrics  *Metrics
+	adaptive *AdaptiveController
+	pool     *senderPool
+	types    *typeGuard
+
+	destinations []*destination // 发送目标集群，第一个为主集群
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -24,37 +32,74 @@ type Client struct {
 	hostName string
 	hostIP   string
 
+	// 热更新
+	reloadMu      sync.Mutex
+	flushInterval chan time.Duration // 通知定时刷新协程调整间隔
+
 	closed bool
 	mu     sync.Mutex
 }
 
 // NewClient 创建新的ELK客户端
+// 客户端使用配置的副本，副本中新增配置项的零值替换为默认值
 func NewClient(config *Config) (*Client, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	config = config.withDefaults()
 
 	// 验证配置
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	// 创建发送器
-	sender, err := NewSender(config)
+	// 为每个集群创建发送器，发送器与客户端共享指标
+	metrics := NewMetrics()
+	destinations, err := newDestinations(config, metrics)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create sender: %w", err)
 	}
 
+	batch := NewBatch(config.BatchSize, config.BatchTimeout)
+	batch.SetMaxBytes(config.MaxBatchBytes)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &Client{
-		config:  config,
-		sender:  sender,
-		batch:   NewBatch(config.BatchSize, config.BatchTimeout),
-		queue:   make(chan *LogEntry, config.QueueSize),
-		metrics: NewMetrics(),
-		ctx:     ctx,
-		cancel:  cancel,
+		batch:         batch,
+		lanes:         newLanes(config),
+		metrics:       metrics,
+		types:         newTypeGuard(metrics),
+		destinations:  destinations,
+		flushInterval: make(chan time.Duration, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	client.config.Store(config)
+
+	// 自适应批量
+	metrics.SetBatchParams(config.BatchSize, config.BatchTimeout)
+	if config.AdaptiveBatch {
+		client.adaptive = NewAdaptiveController(config, batch, metrics)
+	}
+
+	// 熔断器，每个集群独立熔断，OnCircuitStateChange只通知主集群的状态变化
+	if config.CircuitBreakerThreshold > 0 {
+		for _, d := range destinations {
+			d.breaker = NewCircuitBreaker(config.CircuitBreakerThreshold, config.CircuitBreakerTimeout,
+				func(from, to CircuitState) {
+					if client.multiCluster() {
+						metrics.SetClusterCircuitState(d.name, to)
+					}
+					if d.cluster != nil {
+						return
+					}
+					metrics.SetCircuitState(to)
+					if onChange := client.cfg().OnCircuitStateChange; onChange != nil {
+						onChange(from, to)
+					}
+				})
+		}
 	}
 
 	// 获取主机信息
@@ -63,6 +108,9 @@ func NewClient(config *Config) (*Client, error) {
 		client.hostIP = getLocalIP()
 	}
 
+	// 启动发送协程池
+	client.pool = newSenderPool(config.SenderCount, client.send, metrics)
+
 	// 启动工作协程
 	client.startWorkers()
 
@@ -73,103 +121,218 @@ func NewClient(config *Config) (*Client, error) {
 }
 
 // Log 记录日志
-func (c *Client) Log(level LogLevel, message string, fields Fields) error {
+// 字段在调用期间被复制，返回后调用方可以继续修改传入的Fields
+func (c *Client) Log(level LogLevel, message string, fields ...Fields) error {
+	return c.log(level, message, fields, nil)
+}
+
+// LogTyped 使用强类型字段（如elk.String、elk.Int）记录日志
+// 字段值直接保存在条目中，不分配map也不装箱为interface{}
+func (c *Client) LogTyped(level LogLevel, message string, fields ...Field) error {
+	return c.log(level, message, nil, fields)
+}
+
+// log 记录带Fields和强类型字段的日志
+func (c *Client) log(level LogLevel, message string, fields []Fields, typed []Field) error {
+	// 级别过滤和采样
+	if !c.shouldLog(level) {
+		return nil
+	}
+
+	entry, err := c.newEntry(level, message, fields, typed)
+	if err != nil {
+		return err
+	}
+
+	// 发送到队列
+	return c.enqueue(entry)
+}
+
+// shouldLog 判断日志是否通过级别过滤和采样
+// error及以上级别不参与采样
+func (c *Client) shouldLog(level LogLevel) bool {
+	config := c.cfg()
+
+	if config.MinLevel != "" && level.Priority() < config.MinLevel.Priority() {
+		return false
+	}
+
+	if config.SampleRate > 0 && config.SampleRate < 1 && level.Priority() < LevelError.Priority() {
+		if rand.Float64() >= config.SampleRate {
+			c.metrics.IncSampled()
+			return false
+		}
+	}
+
+	return true
+}
+
+// newEntry 创建日志条目并添加客户端元数据
+func (c *Client) newEntry(level LogLevel, message string, fields []Fields, typed []Field) (*LogEntry, error) {
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
-		return fmt.Errorf("client is closed")
+		return nil, fmt.Errorf("client is closed")
 	}
 	c.mu.Unlock()
 
-	entry := NewLogEntry(level, message, fields)
-
 	// 添加元数据
-	entry.ServiceName = c.config.ServiceName
-	entry.Environment = c.config.Environment
+	config := c.cfg()
+	entry := acquireEntry(level, message)
+	entry.addFields(fields)
+	entry.TypedFields = append(entry.TypedFields, typed...)
+	c.types.apply(config, entry)
+	entry.ServiceName = config.ServiceName
+	entry.Environment = config.Environment
 
-	if c.config.EnableHostInfo {
+	if config.EnableHostInfo {
 		entry.HostName = c.hostName
 		entry.IP = c.hostIP
 	}
 
 	c.metrics.IncTotal()
 
-	// 发送到队列
-	select {
-	case c.queue <- entry:
-		return nil
-	default:
-		// 队列满
-		if c.config.DiscardOnFull {
-			c.metrics.IncDropped()
-			return nil
-		}
-
-		// 阻塞等待
-		select {
-		case c.queue <- entry:
-			return nil
-		case <-time.After(5 * time.Second):
-			c.metrics.IncDropped()
-			return fmt.Errorf("queue is full, log dropped")
-		}
+	// reject策略下自定义字段与内置字段同名时拒绝整条日志
+	if err := newDocumentEncoder(config).checkFields(entry); err != nil {
+		c.metrics.IncRejected()
+		releaseEntry(entry)
+		return nil, err
 	}
+
+	return entry, nil
 }
 
 // Debug 记录Debug级别日志
-func (c *Client) Debug(message string, fields Fields) error {
-	return c.Log(LevelDebug, message, fields)
+func (c *Client) Debug(message string, fields ...Fields) error {
+	return c.log(LevelDebug, message, fields, nil)
 }
 
 // Info 记录Info级别日志
-func (c *Client) Info(message string, fields Fields) error {
-	return c.Log(LevelInfo, message, fields)
+func (c *Client) Info(message string, fields ...Fields) error {
+	return c.log(LevelInfo, message, fields, nil)
 }
 
 // Warn 记录Warn级别日志
-func (c *Client) Warn(message string, fields Fields) error {
-	return c.Log(LevelWarn, message, fields)
+func (c *Client) Warn(message string, fields ...Fields) error {
+	return c.log(LevelWarn, message, fields, nil)
 }
 
 // Error 记录Error级别日志
-func (c *Client) Error(message string, fields Fields) error {
-	return c.Log(LevelError, message, fields)
+func (c *Client) Error(message string, fields ...Fields) error {
+	return c.log(LevelError, message, fields, nil)
+}
+
+// DebugTyped 使用强类型字段记录Debug级别日志
+func (c *Client) DebugTyped(message string, fields ...Field) error {
+	return c.log(LevelDebug, message, nil, fields)
+}
+
+// InfoTyped 使用强类型字段记录Info级别日志
+func (c *Client) InfoTyped(message string, fields ...Field) error {
+	return c.log(LevelInfo, message, nil, fields)
+}
+
+// WarnTyped 使用强类型字段记录Warn级别日志
+func (c *Client) WarnTyped(message string, fields ...Field) error {
+	return c.log(LevelWarn, message, nil, fields)
+}
+
+// ErrorTyped 使用强类型字段记录Error级别日志
+func (c *Client) ErrorTyped(message string, fields ...Field) error {
+	return c.log(LevelError, message, nil, fields)
 }
 
 // Fatal 记录Fatal级别日志
-func (c *Client) Fatal(message string, fields Fields) error {
-	return c.Log(LevelFatal, message, fields)
+// 不经过队列，在FatalFlushTimeout内同步发送后返回，适用于随后退出进程的场景
+func (c *Client) Fatal(message string, fields ...Fields) error {
+	return c.fatal(message, fields, nil)
+}
+
+// FatalTyped 使用强类型字段记录Fatal级别日志，发送方式与Fatal相同
+func (c *Client) FatalTyped(message string, fields ...Field) error {
+	return c.fatal(message, nil, fields)
+}
+
+// fatal 同步发送Fatal日志
+func (c *Client) fatal(message string, fields []Fields, typed []Field) error {
+	entry, err := c.newEntry(LevelFatal, message, fields, typed)
+	if err != nil {
+		return err
+	}
+
+	entries := []*LogEntry{entry}
+	defer c.release(entries)
+
+	return c.deliver(entries, c.cfg().FatalFlushTimeout)
+}
+
+// FatalAndExit 同步发送Fatal日志，刷新所有缓存的日志后以状态码1退出进程
+// 刷新所有日志最多等待FatalFlushTimeout
+func (c *Client) FatalAndExit(message string, fields ...Fields) {
+	_ = c.Fatal(message, fields...)
+	c.exit()
+}
+
+// FatalAndExitTyped 使用强类型字段记录Fatal日志，退出方式与FatalAndExit相同
+func (c *Client) FatalAndExitTyped(message string, fields ...Field) {
+	_ = c.FatalTyped(message, fields...)
+	c.exit()
+}
+
+// exit 在FatalFlushTimeout内关闭客户端后以状态码1退出进程
+func (c *Client) exit() {
+	done := make(chan struct{})
+	go func() {
+		_ = c.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(c.cfg().FatalFlushTimeout):
+	}
+
+	os.Exit(1)
 }
 
 // startWorkers 启动工作协程
 func (c *Client) startWorkers() {
-	for i := 0; i < c.config.WorkerCount; i++ {
+	for i := 0; i < c.cfg().WorkerCount; i++ {
 		c.wg.Add(1)
 		go c.worker()
 	}
 }
 
-// worker 工作协程，从队列接收日志并添加到批次
+// worker 工作协程，按优先级从队列接收日志并添加到批次
+// 满批次交给发送协程池，工作协程本身不做网络IO
 func (c *Client) worker() {
 	defer c.wg.Done()
 
 	for {
-		select {
-		case <-c.ctx.Done():
+		entry, ok := c.next()
+		if !ok {
 			return
-		case entry := <-c.queue:
-			startTime := time.Now()
+		}
 
-			// 添加到批次
-			shouldFlush := c.batch.Add(entry)
+		startTime := time.Now()
 
-			if shouldFlush {
-				c.flush()
-			}
+		// 加入批次后条目可能被其他协程发送并回收，需提前读取级别
+		high := laneOf(entry.Level) == laneHigh
+
+		// 添加到批次
+		shouldFlush := c.batch.Add(entry)
+
+		// error及以上级别可立即刷新，不等待批量超时
+		if c.cfg().FlushOnError && high {
+			shouldFlush = true
+		}
 
-			// 记录延迟
-			c.metrics.RecordLatency(time.Since(startTime))
+		if shouldFlush {
+			c.dispatch()
 		}
+
+		// 记录延迟
+		c.metrics.RecordLatency(time.Since(startTime))
 	}
 }
 
@@ -179,46 +342,140 @@ func (c *Client) startFlusher() {
 	go func() {
 		defer c.wg.Done()
 
-		ticker := time.NewTicker(c.config.FlushInterval)
+		ticker := time.NewTicker(c.cfg().FlushInterval)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-c.ctx.Done():
 				return
+			case interval := <-c.flushInterval:
+				ticker.Reset(interval)
 			case <-ticker.C:
 				if c.batch.ShouldFlush() {
-					c.flush()
+					c.dispatch()
 				}
 			}
 		}
 	}()
 }
 
-// flush 刷新批次
-func (c *Client) flush() {
-	entries := c.batch.Flush()
-	if len(entries) == 0 {
-		return
+// dispatch 取出当前批次并提交给发送协程池
+func (c *Client) dispatch() {
+	c.pool.Submit(c.batch.Flush())
+}
+
+// send 发送一个批次，由发送协程池调用
+func (c *Client) send(entries []*LogEntry) {
+	// 集群拒绝后退避等待，关闭时不再等待
+	if c.adaptive != nil {
+		if backoff := c.adaptive.Backoff(); backoff > 0 {
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(backoff):
+			}
+		}
 	}
 
-	// 发送到ES
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	_ = c.deliver(entries, c.cfg().SendTimeout)
+
+	// 发送成功或降级处理后回收条目和批次切片
+	c.release(entries)
+	c.batch.Recycle(entries)
+}
 
-	err := c.sender.SendWithRetry(ctx, entries)
+// deliver 在超时时间内发送批次，发送失败或熔断时交给降级处理
+// 配置了备用集群时，按ClusterMode故障转移或复制到所有集群，每个集群的超时时间独立计算
+// 只有未写入的条目交给降级处理，已写入的条目不会重复；复制模式下每个条目最多降级处理一次
+// 发送前统一按大小限制处理和编码，超限或字段冲突被拒绝的条目不发送
+func (c *Client) deliver(entries []*LogEntry, timeout time.Duration) error {
+	entries, err := c.prepare(entries)
 	if err != nil {
-		c.metrics.IncFailed()
-		// TODO: 可以将失败的日志写入本地文件
-		fmt.Printf("Failed to send logs to Elasticsearch: %v\n", err)
+		c.fallback(entries, err)
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var failed []*LogEntry
+	if c.multiCluster() && c.cfg().ClusterMode == ClusterModeReplicate {
+		failed, err = c.sendReplicate(entries, timeout)
 	} else {
-		c.metrics.IncSuccess()
+		failed, err = c.sendFailover(entries, timeout)
+	}
+
+	if err == nil {
+		c.metrics.AddSuccess(len(entries))
+		return nil
 	}
+	c.metrics.AddSuccess(len(entries) - len(failed))
+	c.fallback(failed, err)
+	return err
 }
 
-// Flush 手动刷新所有缓存的日志
+// prepare 在发送到各集群之前按大小限制处理并编码批次中的条目，结果记录在条目上
+// 每条日志的截断、拒绝和编码错误只计数一次；返回需要发送的条目，没有条目被拒绝时为entries本身
+func (c *Client) prepare(entries []*LogEntry) ([]*LogEntry, error) {
+	config := c.cfg()
+	encoder := newDocumentEncoder(config)
+
+	rejected := false
+	for _, entry := range entries {
+		document, ok, err := appendLimitedDocument(entry.document[:0], config, encoder, c.metrics, entry)
+		if err != nil {
+			return entries, err
+		}
+		entry.prepared, entry.document = true, document
+		rejected = rejected || !ok
+	}
+
+	if !rejected {
+		return entries, nil
+	}
+	// 被拒绝的条目不发送也不交给降级处理，与原批次一起回收
+	return slices.DeleteFunc(slices.Clone(entries), func(entry *LogEntry) bool {
+		return len(entry.document) == 0
+	}), nil
+}
+
+// observe 根据主集群的发送结果调整自适应批量
+func (c *Client) observe(latency time.Duration, err error) {
+	if c.adaptive != nil {
+		c.adaptive.Observe(latency, c.queueLen(), err)
+	}
+}
+
+// release 回收客户端创建的日志条目
+func (c *Client) release(entries []*LogEntry) {
+	for _, entry := range entries {
+		releaseEntry(entry)
+	}
+}
+
+// fallback 处理发送失败或被熔断的日志
+func (c *Client) fallback(entries []*LogEntry, err error) {
+	c.metrics.AddFailed(len(entries))
+
+	if handler := c.cfg().FallbackHandler; handler != nil {
+		c.metrics.IncFallback()
+		handler(entries, err)
+	}
+}
+
+// CircuitState 返回主集群熔断器当前状态，未启用熔断时始终为关闭
+func (c *Client) CircuitState() CircuitState {
+	breaker := c.destinations[0].breaker
+	if breaker == nil {
+		return CircuitClosed
+	}
+	return breaker.State()
+}
+
+// Flush 手动刷新所有缓存的日志，等待已提交的批次发送完成后返回
 func (c *Client) Flush() {
-	c.flush()
+	c.dispatch()
+	c.pool.Wait()
 }
 
 // GetMetrics 获取监控指标
@@ -242,15 +499,40 @@ func (c *Client) Close() error {
 	// 等待所有工作协程退出
 	c.wg.Wait()
 
-	// 最后刷新一次
-	c.flush()
+	// 将队列中剩余的日志加入批次
+	c.drainQueue()
+
+	// 最后刷新一次，并等待发送协程处理完所有批次
+	c.dispatch()
+	c.pool.Close()
 
 	// 关闭发送器
-	if c.sender != nil {
-		return c.sender.Close()
+	var errs []error
+	for _, d := range c.destinations {
+		if err := d.sender.Load().Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// drainQueue 将队列中剩余的日志加入批次，批次满时提交发送
+func (c *Client) drainQueue() {
+	for {
+		entry, ok := c.tryNext()
+		if !ok {
+			return
+		}
+		if c.batch.Add(entry) {
+			c.dispatch()
+		}
+	}
+}
+
+// cfg 返回当前配置
+func (c *Client) cfg() *Config {
+	return c.config.Load()
 }
 
 // getLocalIP 获取本地IP地址
@@ -0,0 +1,242 @@
+package elk_logger
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// primaryCluster 主集群在指标和错误中的名称
+const primaryCluster = "primary"
+
+// ClusterMode 多集群发送模式
+type ClusterMode string
+
+const (
+	ClusterModeFailover  ClusterMode = "failover"  // 主集群熔断或发送失败时依次尝试备用集群
+	ClusterModeReplicate ClusterMode = "replicate" // 每个批次同时发送到所有集群
+)
+
+// valid 判断模式是否合法
+func (m ClusterMode) valid() bool {
+	return m == ClusterModeFailover || m == ClusterModeReplicate
+}
+
+// ClusterConfig 备用集群配置
+// 连接、认证和TLS配置只使用本集群的值，不沿用主集群；
+// IndexPattern为空时沿用主集群，批量、重试、传输层等其他配置与主集群相同
+type ClusterConfig struct {
+	Name                 string   `json:"name" yaml:"name"` // 集群名称，用于指标和错误
+	ESAddresses          []string `json:"es_addresses" yaml:"addresses"`
+	ESUsername           string   `json:"es_username" yaml:"username"`
+	ESPassword           string   `json:"es_password" yaml:"password"`
+	ESPasswordFile       string   `json:"es_password_file" yaml:"password_file"`
+	ESAPIKey             string   `json:"es_api_key" yaml:"api_key"`
+	ESAPIKeyFile         string   `json:"es_api_key_file" yaml:"api_key_file"`
+	ESServiceToken       string   `json:"es_service_token" yaml:"service_token"`
+	ESServiceTokenFile   string   `json:"es_service_token_file" yaml:"service_token_file"`
+	ESCloudID            string   `json:"es_cloud_id" yaml:"cloud_id"`
+	ESCACert             string   `json:"es_ca_cert" yaml:"ca_cert"`
+	ESClientCert         string   `json:"es_client_cert" yaml:"client_cert"`
+	ESClientKey          string   `json:"es_client_key" yaml:"client_key"`
+	ESInsecureSkipVerify bool     `json:"es_insecure_skip_verify" yaml:"insecure_skip_verify"`
+	IndexPattern         string   `json:"index_pattern" yaml:"index_pattern"`
+}
+
+// forCluster 返回发送到备用集群时使用的配置
+func (c *Config) forCluster(cluster *ClusterConfig) *Config {
+	clone := *c
+	clone.ESAddresses = cluster.ESAddresses
+	clone.ESUsername = cluster.ESUsername
+	clone.ESPassword = cluster.ESPassword
+	clone.ESPasswordFile = cluster.ESPasswordFile
+	clone.ESAPIKey = cluster.ESAPIKey
+	clone.ESAPIKeyFile = cluster.ESAPIKeyFile
+	clone.ESServiceToken = cluster.ESServiceToken
+	clone.ESServiceTokenFile = cluster.ESServiceTokenFile
+	clone.ESCloudID = cluster.ESCloudID
+	clone.ESCACert = cluster.ESCACert
+	clone.ESClientCert = cluster.ESClientCert
+	clone.ESClientKey = cluster.ESClientKey
+	clone.ESInsecureSkipVerify = cluster.ESInsecureSkipVerify
+	if cluster.IndexPattern != "" {
+		clone.IndexPattern = cluster.IndexPattern
+	}
+	clone.Clusters = nil
+	return &clone
+}
+
+// ClusterError 发送到某个集群失败
+type ClusterError struct {
+	Cluster string // 集群名称，主集群为"primary"
+	Err     error
+}
+
+func (e *ClusterError) Error() string {
+	return "cluster " + e.Cluster + ": " + e.Err.Error()
+}
+
+func (e *ClusterError) Unwrap() error {
+	return e.Err
+}
+
+// destination 日志发送目标集群，每个集群有独立的发送器和熔断器
+type destination struct {
+	name    string
+	cluster *ClusterConfig // 备用集群配置，主集群为nil
+	sender  atomic.Pointer[Sender]
+	breaker *CircuitBreaker
+}
+
+// configFor 返回目标集群使用的配置
+func (d *destination) configFor(config *Config) *Config {
+	if d.cluster == nil {
+		return config
+	}
+	return config.forCluster(d.cluster)
+}
+
+// send 在超时时间内发送到目标集群，熔断打开时直接返回ErrCircuitOpen
+// 只有请求级别的失败和条目5xx计入熔断，映射错误等条目级别的4xx不影响集群状态
+func (d *destination) send(entries []*LogEntry, timeout time.Duration) error {
+	if d.breaker != nil && !d.breaker.Allow() {
+		return ErrCircuitOpen
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := d.sender.Load().SendWithRetry(ctx, entries)
+	if d.breaker != nil {
+		if clusterFailure(err) {
+			d.breaker.RecordFailure()
+		} else {
+			d.breaker.RecordSuccess()
+		}
+	}
+	return err
+}
+
+// clusterFailure 判断发送错误是否说明集群故障
+func clusterFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.clusterFailure()
+	}
+	return true
+}
+
+// newDestinations 为主集群和所有备用集群创建发送目标
+func newDestinations(config *Config, metrics *Metrics) ([]*destination, error) {
+	dests := []*destination{{name: primaryCluster}}
+	for i := range config.Clusters {
+		dests = append(dests, &destination{name: config.Clusters[i].Name, cluster: &config.Clusters[i]})
+	}
+
+	for _, d := range dests {
+		sender, err := newSender(d.configFor(config), metrics)
+		if err != nil {
+			if d.cluster == nil {
+				return nil, err
+			}
+			return nil, &ClusterError{Cluster: d.name, Err: err}
+		}
+		d.sender.Store(sender)
+	}
+	return dests, nil
+}
+
+// multiCluster 是否配置了备用集群
+func (c *Client) multiCluster() bool {
+	return len(c.destinations) > 1
+}
+
+// sendFailover 依次尝试各集群直到发送成功，返回所有集群都未能写入的条目
+// 已写入的条目不会再发往下一个集群；只有一个集群时直接返回其错误
+func (c *Client) sendFailover(entries []*LogEntry, timeout time.Duration) ([]*LogEntry, error) {
+	var errs []error
+	for i, d := range c.destinations {
+		start := time.Now()
+		err := d.send(entries, timeout)
+		if i == 0 {
+			c.observe(time.Since(start), err)
+		}
+		failed := failedEntries(entries, err)
+		c.recordCluster(d, len(entries), failed, err)
+
+		if err == nil {
+			return nil, nil
+		}
+		if !c.multiCluster() {
+			return failed, err
+		}
+		errs = append(errs, &ClusterError{Cluster: d.name, Err: err})
+		entries = failed
+	}
+	return entries, errors.Join(errs...)
+}
+
+// sendReplicate 并发发送到所有集群，返回未写入任一集群的条目
+// 同一条目在多个集群失败时只返回一次，错误为失败集群的*ClusterError，多个集群失败时合并
+func (c *Client) sendReplicate(entries []*LogEntry, timeout time.Duration) ([]*LogEntry, error) {
+	failed := make([][]*LogEntry, len(c.destinations))
+	errs := make([]error, len(c.destinations))
+
+	var wg sync.WaitGroup
+	for i, d := range c.destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+			err := d.send(entries, timeout)
+			if i == 0 {
+				c.observe(time.Since(start), err)
+			}
+			if err != nil {
+				failed[i] = failedEntries(entries, err)
+				errs[i] = &ClusterError{Cluster: d.name, Err: err}
+			}
+			c.recordCluster(d, len(entries), failed[i], err)
+		}()
+	}
+	wg.Wait()
+
+	errs = slices.DeleteFunc(errs, func(err error) bool { return err == nil })
+	switch len(errs) {
+	case 0:
+		return nil, nil
+	case 1:
+		return slices.Concat(failed...), errs[0]
+	}
+
+	// 按批次中的顺序合并各集群未写入的条目
+	missing := make(map[*LogEntry]bool)
+	for _, entries := range failed {
+		for _, entry := range entries {
+			missing[entry] = true
+		}
+	}
+	return slices.DeleteFunc(slices.Clone(entries), func(entry *LogEntry) bool { return !missing[entry] }), errors.Join(errs...)
+}
+
+// recordCluster 记录各集群写入成功和失败的日志数，只有一个集群时不记录
+func (c *Client) recordCluster(d *destination, logs int, failed []*LogEntry, err error) {
+	if !c.multiCluster() {
+		return
+	}
+	if err == nil {
+		c.metrics.RecordClusterSend(d.name, logs, nil)
+		return
+	}
+	if n := logs - len(failed); n > 0 {
+		c.metrics.RecordClusterSend(d.name, n, nil)
+	}
+	c.metrics.RecordClusterSend(d.name, len(failed), err)
+}
@@ -1,6 +1,15 @@
 package elk_logger
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
 
 // Config ELK日志采集器配置
 type Config struct {
@@ -10,73 +19,514 @@ type Config struct {
 	ESPassword   string   `json:"es_password"`   // ES密码
 	IndexPattern string   `json:"index_pattern"` // 索引模式，如 "logs-{date}"
 
+	// 认证配置，APIKey、ServiceToken优先于用户名密码
+	ESPasswordFile     string `json:"es_password_file"`      // 从文件读取ES密码
+	ESAPIKey           string `json:"es_api_key"`            // Base64编码的API Key
+	ESAPIKeyFile       string `json:"es_api_key_file"`       // 从文件读取API Key
+	ESServiceToken     string `json:"es_service_token"`      // 服务账号令牌（Bearer）
+	ESServiceTokenFile string `json:"es_service_token_file"` // 从文件读取服务账号令牌
+	ESCloudID          string `json:"es_cloud_id"`           // Elastic Cloud部署ID，设置后忽略ESAddresses
+
+	// TLS配置
+	ESCACert             string `json:"es_ca_cert"`              // CA证书文件（PEM）
+	ESClientCert         string `json:"es_client_cert"`          // 客户端证书文件（PEM）
+	ESClientKey          string `json:"es_client_key"`           // 客户端私钥文件（PEM）
+	ESInsecureSkipVerify bool   `json:"es_insecure_skip_verify"` // 跳过服务端证书校验，仅用于测试
+
+	// 多集群配置
+	Clusters    []ClusterConfig `json:"clusters"`     // 备用集群，按顺序作为故障转移目标，或在复制模式下同时发送
+	ClusterMode ClusterMode     `json:"cluster_mode"` // 多集群发送模式，为空时为failover
+
+	// 节点发现配置
+	EnableSniffing       bool          `json:"enable_sniffing"`        // 启动时嗅探集群节点，只向可接收写入的节点发送并优先选择仅协调节点
+	SniffInterval        time.Duration `json:"sniff_interval"`         // 定期重新嗅探的间隔（0表示只在启动时嗅探）
+	NodeResurrectTimeout time.Duration `json:"node_resurrect_timeout"` // 节点被标记为不可用后首次重试前的等待时间，连续失败时翻倍
+
 	// 批量发送配置
-	BatchSize     int           `json:"batch_size"`     // 批量大小（条数）
-	BatchTimeout  time.Duration `json:"batch_timeout"`  // 批量超时时间
-	FlushInterval time.Duration `json:"flush_interval"` // 强制刷新间隔
+	BatchSize     int           `json:"batch_size"`      // 批量大小（条数）
+	BatchTimeout  time.Duration `json:"batch_timeout"`   // 批量超时时间
+	FlushInterval time.Duration `json:"flush_interval"`  // 强制刷新间隔
+	MaxBatchBytes int           `json:"max_batch_bytes"` // 单个批量请求最大字节数（0表示不限制）
+
+	// 自适应批量配置
+	AdaptiveBatch   bool          `json:"adaptive_batch"`    // 是否根据集群反馈自动调整批量大小
+	MinBatchSize    int           `json:"min_batch_size"`    // 自适应批量大小下限
+	MaxBatchSize    int           `json:"max_batch_size"`    // 自适应批量大小上限
+	MinBatchTimeout time.Duration `json:"min_batch_timeout"` // 自适应批量超时下限
+	MaxBatchTimeout time.Duration `json:"max_batch_timeout"` // 自适应批量超时上限
+	TargetLatency   time.Duration `json:"target_latency"`    // 批量请求目标延迟，超过则缩小批量
+
+	// 单条文档配置
+	MaxDocumentBytes    int            `json:"max_document_bytes"`    // 单条文档最大字节数（0表示不限制）
+	TruncateOversizeDoc bool           `json:"truncate_oversize_doc"` // 超限文档是否截断（false则直接拒绝）
+	DocumentFormat      DocumentFormat `json:"document_format"`       // 文档格式：legacy（默认）或ecs
+
+	// 长度限制配置，超限内容按UTF-8字符边界截断，文档中记录 truncated 和 original_size
+	MaxMessageBytes int `json:"max_message_bytes"` // 消息最大字节数（0表示不限制）
+	MaxStackBytes   int `json:"max_stack_bytes"`   // 堆栈最大字节数（0表示不限制）
+	MaxFieldBytes   int `json:"max_field_bytes"`   // 单个字符串字段值最大字节数（0表示不限制）
+
+	// 自定义字段配置
+	FieldsNamespace      string               `json:"fields_namespace"`       // 自定义字段嵌套到的顶层键，如labels、fields（为空时平铺到顶层）
+	FieldCollision       FieldCollisionPolicy `json:"field_collision"`        // 平铺时与内置字段同名的处理方式：prefix（默认）、reject、overwrite
+	FieldCollisionPrefix string               `json:"field_collision_prefix"` // prefix策略使用的前缀
+	ExpandDottedKeys     bool                 `json:"expand_dotted_keys"`     // 是否将点分键（如http.method）展开为嵌套对象
+	MaxFieldDepth        int                  `json:"max_field_depth"`        // 字段值（结构体、map、切片）最大嵌套层数，超过的部分写为null（0表示不限制）
+
+	// 字段类型保护配置，避免同名字段类型不一致导致ES映射冲突
+	FieldTypes         map[string]FieldType `json:"field_types"`          // 字段的期望类型，如 user_id: string
+	InferFieldTypes    bool                 `json:"infer_field_types"`    // 未在FieldTypes中的字段以首次出现的类型为准
+	TypeConflictPolicy TypeConflictPolicy   `json:"type_conflict_policy"` // 类型冲突时的处理方式：coerce（默认）、rename、stringify
 
 	// 队列配置
-	QueueSize   int `json:"queue_size"`   // 队列大小
-	WorkerCount int `json:"worker_count"` // 工作协程数
+	QueueSize             int  `json:"queue_size"`               // 队列大小（info、warn级别通道）
+	HighPriorityQueueSize int  `json:"high_priority_queue_size"` // error及以上级别的预留队列大小
+	LowPriorityQueueSize  int  `json:"low_priority_queue_size"`  // debug级别队列大小
+	WorkerCount           int  `json:"worker_count"`             // 工作协程数
+	FlushOnError          bool `json:"flush_on_error"`           // error及以上级别日志是否立即触发刷新
+
+	// 队列满处理配置
+	QueueFullPolicy   QueueFullPolicy `json:"queue_full_policy"`   // 队列满时的处理策略
+	EnqueueTimeout    time.Duration   `json:"enqueue_timeout"`     // 阻塞等待入队的超时时间
+	PriorityDropLevel LogLevel        `json:"priority_drop_level"` // 按优先级丢弃时，低于此级别的日志直接丢弃
+
+	// 发送配置
+	SenderCount         int `json:"sender_count"`          // 发送协程数
+	MaxInflightRequests int `json:"max_inflight_requests"` // 同时进行的批量请求上限（0表示不限制）
 
 	// 重试配置
-	RetryCount    int           `json:"retry_count"`    // 重试次数
-	RetryInterval time.Duration `json:"retry_interval"` // 重试间隔
+	RetryCount     int           `json:"retry_count"`     // 重试次数
+	RetryInterval  time.Duration `json:"retry_interval"`  // 首次重试的退避基数，之后按指数增长并加入随机抖动
+	RequestTimeout time.Duration `json:"request_timeout"` // 单次批量请求的超时时间（0表示不限制）
+	SendTimeout    time.Duration `json:"send_timeout"`    // 一个批次发送的总超时时间，包含全部重试
+
+	// 熔断配置
+	CircuitBreakerThreshold int           `json:"circuit_breaker_threshold"` // 连续失败多少次后熔断（0表示不启用）
+	CircuitBreakerTimeout   time.Duration `json:"circuit_breaker_timeout"`   // 熔断后多久进入半开状态探测
 
 	// 应用信息
 	ServiceName    string `json:"service_name"`     // 服务名称
 	Environment    string `json:"environment"`      // 环境
 	EnableHostInfo bool   `json:"enable_host_info"` // 是否添加主机信息
 
+	// 过滤配置
+	MinLevel   LogLevel `json:"min_level"`   // 最低记录级别，为空表示记录所有级别
+	SampleRate float64  `json:"sample_rate"` // error以下级别的采样率，取值(0,1)时生效，0或1表示不采样
+
+	// Fatal日志配置
+	FatalFlushTimeout   time.Duration `json:"fatal_flush_timeout"`   // Fatal日志同步发送的超时时间
+	RepanicAfterRecover bool          `json:"repanic_after_recover"` // RecoverAndLog记录panic后是否重新抛出
+
 	// 高级配置
 	EnableCompression bool          `json:"enable_compression"` // 是否启用压缩
 	MaxRetryBackoff   time.Duration `json:"max_retry_backoff"`  // 最大重试退避时间
-	DiscardOnFull     bool          `json:"discard_on_full"`    // 队列满时是否丢弃
+	DiscardOnFull     bool          `json:"discard_on_full"`    // 队列满时是否丢弃（兼容旧配置，等同于drop_newest策略）
+
+	// 传输层配置
+	ConnectionTimeout     time.Duration     `json:"connection_timeout"`      // 建立TCP连接的超时时间
+	ResponseHeaderTimeout time.Duration     `json:"response_header_timeout"` // 等待响应头的超时时间（0表示不限制）
+	MaxIdleConns          int               `json:"max_idle_conns"`          // 最大空闲连接数
+	MaxIdleConnsPerHost   int               `json:"max_idle_conns_per_host"` // 每个节点的最大空闲连接数
+	Transport             http.RoundTripper `json:"-"`                       // 自定义传输层，设置后忽略上述传输层配置和TLS配置
+
+	// 调试配置
+	EnableDebug bool      `json:"enable_debug"` // 是否将ES请求和响应写入DebugWriter
+	DebugWriter io.Writer `json:"-"`            // 调试日志输出，为空时使用标准错误
+
+	// 回调配置
+	FallbackHandler      func(entries []*LogEntry, err error) `json:"-"` // 发送失败或熔断时的降级处理（如写入本地文件），entries只包含未写入的条目，err为*SendError时可取得各条目的失败原因；复制模式下未写入任一集群的条目合并后只交给降级处理一次，err包含每个失败集群的*ClusterError；返回后entries会被回收，需要保留时使用Clone；为空时失败的日志只计入FailedLogs
+	OnCircuitStateChange func(from, to CircuitState)          `json:"-"` // 熔断器状态变化回调
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		ESAddresses:       []string{"http://localhost:9200"},
-		IndexPattern:      "logs-{date}",
-		BatchSize:         100,
-		BatchTimeout:      5 * time.Second,
-		FlushInterval:     10 * time.Second,
-		QueueSize:         10000,
-		WorkerCount:       4,
-		RetryCount:        3,
-		RetryInterval:     1 * time.Second,
-		MaxRetryBackoff:   30 * time.Second,
-		ServiceName:       "unknown-service",
-		Environment:       "development",
-		EnableHostInfo:    true,
-		EnableCompression: true,
-		DiscardOnFull:     false,
+		ESAddresses:             []string{"http://localhost:9200"},
+		IndexPattern:            "logs-{date}",
+		SniffInterval:           5 * time.Minute,
+		NodeResurrectTimeout:    60 * time.Second,
+		BatchSize:               100,
+		BatchTimeout:            5 * time.Second,
+		FlushInterval:           10 * time.Second,
+		MaxBatchBytes:           5 * 1024 * 1024,
+		MaxDocumentBytes:        1024 * 1024,
+		TruncateOversizeDoc:     true,
+		DocumentFormat:          FormatLegacy,
+		FieldCollision:          CollisionPrefix,
+		FieldCollisionPrefix:    defaultCollisionPrefix,
+		MaxFieldDepth:           defaultMaxFieldDepth,
+		TypeConflictPolicy:      TypeConflictCoerce,
+		MinBatchSize:            10,
+		MaxBatchSize:            2000,
+		MinBatchTimeout:         1 * time.Second,
+		MaxBatchTimeout:         30 * time.Second,
+		TargetLatency:           500 * time.Millisecond,
+		QueueSize:               10000,
+		HighPriorityQueueSize:   1000,
+		LowPriorityQueueSize:    5000,
+		WorkerCount:             4,
+		QueueFullPolicy:         PolicyBlockTimeout,
+		EnqueueTimeout:          5 * time.Second,
+		PriorityDropLevel:       LevelWarn,
+		SenderCount:             2,
+		MaxInflightRequests:     2,
+		RetryCount:              3,
+		RetryInterval:           1 * time.Second,
+		MaxRetryBackoff:         30 * time.Second,
+		RequestTimeout:          10 * time.Second,
+		SendTimeout:             30 * time.Second,
+		CircuitBreakerThreshold: 0, // 默认不启用熔断，启用时应同时设置FallbackHandler
+		CircuitBreakerTimeout:   30 * time.Second,
+		ServiceName:             "unknown-service",
+		Environment:             "development",
+		EnableHostInfo:          true,
+		MinLevel:                LevelDebug,
+		SampleRate:              1,
+		FatalFlushTimeout:       5 * time.Second,
+		EnableCompression:       true,
+		DiscardOnFull:           false,
+		ConnectionTimeout:       10 * time.Second,
+		ResponseHeaderTimeout:   30 * time.Second,
+		MaxIdleConns:            100,
+		MaxIdleConnsPerHost:     10,
 	}
 }
 
-// Validate 验证配置
+// maxWorkerCount 工作协程和发送协程数量上限
+const maxWorkerCount = 1024
+
+// maxIndexNameBytes ES索引名最大字节数
+const maxIndexNameBytes = 255
+
+// Validate 验证配置，一次性返回所有问题，不修改配置
 func (c *Config) Validate() error {
-	if len(c.ESAddresses) == 0 {
-		return ErrInvalidConfig{msg: "es_addresses cannot be empty"}
+	var errs ValidationErrors
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, ErrInvalidConfig{field: field, msg: field + " " + fmt.Sprintf(format, args...)})
+	}
+
+	// Elasticsearch
+	c.validateConnection(invalid)
+
+	// 多集群
+	if c.ClusterMode != "" && !c.ClusterMode.valid() {
+		invalid("cluster_mode", "has unknown value %q", c.ClusterMode)
+	}
+	names := map[string]bool{primaryCluster: true}
+	for i := range c.Clusters {
+		cluster := &c.Clusters[i]
+		if cluster.Name == "" || names[cluster.Name] {
+			invalid("clusters", "[%d] name must be unique, not empty and not %q", i, primaryCluster)
+		}
+		names[cluster.Name] = true
+		c.forCluster(cluster).validateConnection(func(field, format string, args ...interface{}) {
+			invalid("clusters", "[%s] %s %s", cluster.Name, field, fmt.Sprintf(format, args...))
+		})
+	}
+	if c.SniffInterval < 0 {
+		invalid("sniff_interval", "cannot be negative")
+	}
+	if c.NodeResurrectTimeout <= 0 {
+		invalid("node_resurrect_timeout", "must be greater than 0")
+	}
+	if c.Transport != nil && (c.ESCACert != "" || c.ESClientCert != "" || c.ESInsecureSkipVerify) {
+		invalid("transport", "cannot be used together with tls options, configure tls on the custom transport")
+	}
+
+	// 传输层
+	if c.ConnectionTimeout < 0 {
+		invalid("connection_timeout", "cannot be negative")
 	}
+	if c.ResponseHeaderTimeout < 0 {
+		invalid("response_header_timeout", "cannot be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		invalid("max_idle_conns", "cannot be negative")
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		invalid("max_idle_conns_per_host", "cannot be negative")
+	}
+
+	// 批量
 	if c.BatchSize <= 0 {
-		return ErrInvalidConfig{msg: "batch_size must be greater than 0"}
+		invalid("batch_size", "must be greater than 0")
+	}
+	if c.BatchTimeout <= 0 {
+		invalid("batch_timeout", "must be greater than 0")
+	}
+	if c.FlushInterval <= 0 {
+		invalid("flush_interval", "must be greater than 0")
+	}
+	if c.MaxBatchBytes < 0 {
+		invalid("max_batch_bytes", "cannot be negative")
+	}
+	if c.MaxDocumentBytes < 0 {
+		invalid("max_document_bytes", "cannot be negative")
+	}
+	if c.MaxBatchBytes > 0 && c.MaxDocumentBytes > c.MaxBatchBytes {
+		invalid("max_document_bytes", "cannot exceed max_batch_bytes")
+	}
+	if c.MaxMessageBytes < 0 {
+		invalid("max_message_bytes", "cannot be negative")
+	}
+	if c.MaxStackBytes < 0 {
+		invalid("max_stack_bytes", "cannot be negative")
+	}
+	if c.MaxFieldBytes < 0 {
+		invalid("max_field_bytes", "cannot be negative")
+	}
+	if c.DocumentFormat != "" && !c.DocumentFormat.valid() {
+		invalid("document_format", "has unknown value %q", c.DocumentFormat)
+	}
+	if c.FieldCollision != "" && !c.FieldCollision.valid() {
+		invalid("field_collision", "has unknown value %q", c.FieldCollision)
 	}
+	if slices.Contains(reservedNamespaces, c.FieldsNamespace) || strings.Contains(c.FieldsNamespace, ".") {
+		invalid("fields_namespace", "%q is reserved or contains a dot", c.FieldsNamespace)
+	}
+	if c.MaxFieldDepth < 0 {
+		invalid("max_field_depth", "cannot be negative")
+	}
+	for key, t := range c.FieldTypes {
+		if key == "" || !t.valid() {
+			invalid("field_types", "has invalid entry %q: %q", key, t)
+		}
+	}
+	if c.TypeConflictPolicy != "" && !c.TypeConflictPolicy.valid() {
+		invalid("type_conflict_policy", "has unknown value %q", c.TypeConflictPolicy)
+	}
+	if c.AdaptiveBatch {
+		if c.MinBatchSize <= 0 || c.MinBatchSize > c.MaxBatchSize {
+			invalid("min_batch_size", "must be greater than 0 and not exceed max_batch_size")
+		}
+		if c.BatchSize < c.MinBatchSize || c.BatchSize > c.MaxBatchSize {
+			invalid("batch_size", "must be between min_batch_size and max_batch_size")
+		}
+		if c.MinBatchTimeout <= 0 || c.MinBatchTimeout > c.MaxBatchTimeout {
+			invalid("min_batch_timeout", "must be greater than 0 and not exceed max_batch_timeout")
+		}
+		if c.BatchTimeout < c.MinBatchTimeout || c.BatchTimeout > c.MaxBatchTimeout {
+			invalid("batch_timeout", "must be between min_batch_timeout and max_batch_timeout")
+		}
+		if c.TargetLatency <= 0 {
+			invalid("target_latency", "must be greater than 0")
+		}
+	}
+
+	// 队列
 	if c.QueueSize <= 0 {
-		return ErrInvalidConfig{msg: "queue_size must be greater than 0"}
+		invalid("queue_size", "must be greater than 0")
+	} else if c.QueueSize < c.BatchSize {
+		invalid("queue_size", "must not be smaller than batch_size")
+	}
+	if c.HighPriorityQueueSize <= 0 {
+		invalid("high_priority_queue_size", "must be greater than 0")
+	}
+	if c.LowPriorityQueueSize <= 0 {
+		invalid("low_priority_queue_size", "must be greater than 0")
+	}
+	if c.WorkerCount <= 0 || c.WorkerCount > maxWorkerCount {
+		invalid("worker_count", "must be between 1 and %d", maxWorkerCount)
+	}
+	if c.QueueFullPolicy != "" && !c.QueueFullPolicy.valid() {
+		invalid("queue_full_policy", "has unknown value %q", c.QueueFullPolicy)
+	}
+	switch c.queueFullPolicy() {
+	case PolicyBlockTimeout, PolicyDropByPriority:
+		if c.EnqueueTimeout <= 0 {
+			invalid("enqueue_timeout", "must be greater than 0")
+		}
+	}
+	if c.queueFullPolicy() == PolicyDropByPriority && !c.PriorityDropLevel.valid() {
+		invalid("priority_drop_level", "has unknown value %q", c.PriorityDropLevel)
+	}
+
+	// 发送
+	if c.SenderCount <= 0 || c.SenderCount > maxWorkerCount {
+		invalid("sender_count", "must be between 1 and %d", maxWorkerCount)
+	}
+	if c.MaxInflightRequests < 0 {
+		invalid("max_inflight_requests", "cannot be negative")
+	}
+
+	// 重试与熔断
+	if c.RetryCount < 0 {
+		invalid("retry_count", "cannot be negative")
+	}
+	if c.RetryInterval < 0 {
+		invalid("retry_interval", "cannot be negative")
+	}
+	if c.RetryCount > 0 && c.MaxRetryBackoff < c.RetryInterval {
+		invalid("max_retry_backoff", "must not be smaller than retry_interval")
+	}
+	if c.RequestTimeout < 0 {
+		invalid("request_timeout", "cannot be negative")
+	}
+	if c.SendTimeout <= 0 {
+		invalid("send_timeout", "must be greater than 0")
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		invalid("circuit_breaker_threshold", "cannot be negative")
+	}
+	if c.CircuitBreakerThreshold > 0 && c.CircuitBreakerTimeout <= 0 {
+		invalid("circuit_breaker_timeout", "must be greater than 0")
+	}
+
+	// 应用与过滤
+	if c.MinLevel != "" && !c.MinLevel.valid() {
+		invalid("min_level", "has unknown value %q", c.MinLevel)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		invalid("sample_rate", "must be between 0 and 1")
+	}
+	if c.FatalFlushTimeout <= 0 {
+		invalid("fatal_flush_timeout", "must be greater than 0")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// withDefaults 返回配置的副本，后续版本新增且零值不合法的配置项设为DefaultConfig中的值
+// 只设置了原有字段的字面量配置因此仍然可用；原有字段和0表示不限制或关闭的配置项保持不变，由Validate校验
+func (c *Config) withDefaults() *Config {
+	config := *c
+	defaults := DefaultConfig()
+	setDefault(&config.NodeResurrectTimeout, defaults.NodeResurrectTimeout)
+	setDefault(&config.HighPriorityQueueSize, defaults.HighPriorityQueueSize)
+	setDefault(&config.LowPriorityQueueSize, defaults.LowPriorityQueueSize)
+	setDefault(&config.EnqueueTimeout, defaults.EnqueueTimeout)
+	setDefault(&config.PriorityDropLevel, defaults.PriorityDropLevel)
+	setDefault(&config.SenderCount, defaults.SenderCount)
+	setDefault(&config.SendTimeout, defaults.SendTimeout)
+	setDefault(&config.FatalFlushTimeout, defaults.FatalFlushTimeout)
+	return &config
+}
+
+// setDefault 字段为零值时设为默认值
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
+
+// validateConnection 校验ES连接、认证和索引配置，主集群和备用集群共用
+func (c *Config) validateConnection(invalid func(field, format string, args ...interface{})) {
+	if c.ESCloudID == "" {
+		if len(c.ESAddresses) == 0 {
+			invalid("es_addresses", "cannot be empty")
+		}
+		for _, addr := range c.ESAddresses {
+			u, err := url.Parse(addr)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid("es_addresses", "contains invalid url %q, want http(s)://host:port", addr)
+			}
+		}
+	}
+	if err := validateIndexPattern(c.IndexPattern); err != nil {
+		invalid("index_pattern", "%v", err)
+	}
+
+	// 认证与TLS
+	if c.ESPassword != "" && c.ESPasswordFile != "" {
+		invalid("es_password_file", "cannot be used together with es_password")
+	}
+	if c.ESAPIKey != "" && c.ESAPIKeyFile != "" {
+		invalid("es_api_key_file", "cannot be used together with es_api_key")
 	}
-	if c.WorkerCount <= 0 {
-		return ErrInvalidConfig{msg: "worker_count must be greater than 0"}
+	if c.ESServiceToken != "" && c.ESServiceTokenFile != "" {
+		invalid("es_service_token_file", "cannot be used together with es_service_token")
+	}
+	if (c.ESAPIKey != "" || c.ESAPIKeyFile != "") && (c.ESServiceToken != "" || c.ESServiceTokenFile != "") {
+		invalid("es_service_token", "cannot be used together with es_api_key")
+	}
+	if (c.ESClientCert == "") != (c.ESClientKey == "") {
+		invalid("es_client_cert", "and es_client_key must be set together")
+	}
+	if c.EnableSniffing && c.ESCloudID != "" {
+		invalid("enable_sniffing", "is not supported with es_cloud_id")
+	}
+}
+
+// validateIndexPattern 校验索引模式替换占位符后是否为合法的ES索引名
+func validateIndexPattern(pattern string) error {
+	if pattern == "" {
+		return errors.New("cannot be empty")
+	}
+
+	// 用最长的日期值替换占位符
+	name := pattern
+	name = strings.ReplaceAll(name, "{date}", "2006.01.02")
+	name = strings.ReplaceAll(name, "{year}", "2006")
+	name = strings.ReplaceAll(name, "{month}", "01")
+	name = strings.ReplaceAll(name, "{day}", "02")
+
+	if strings.ContainsAny(name, "{}") {
+		return errors.New("contains unknown placeholder, supported: {date} {year} {month} {day}")
+	}
+	if strings.ToLower(name) != name {
+		return errors.New("must be lowercase")
+	}
+	if i := strings.IndexAny(name, "\\/*?\"<>| ,#:"); i >= 0 {
+		return fmt.Errorf("contains forbidden character %q", name[i])
+	}
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "_") || strings.HasPrefix(name, "+") {
+		return errors.New("cannot start with '-', '_' or '+'")
+	}
+	if name == "." || name == ".." {
+		return errors.New("cannot be '.' or '..'")
+	}
+	if len(name) > maxIndexNameBytes {
+		return fmt.Errorf("cannot be longer than %d bytes", maxIndexNameBytes)
 	}
 	return nil
 }
 
+// queueFullPolicy 返回实际生效的队列满策略
+func (c *Config) queueFullPolicy() QueueFullPolicy {
+	if c.DiscardOnFull {
+		return PolicyDropNewest
+	}
+	if c.QueueFullPolicy == "" {
+		return PolicyBlockTimeout
+	}
+	return c.QueueFullPolicy
+}
+
 // ErrInvalidConfig 配置错误
 type ErrInvalidConfig struct {
-	msg string
+	field string // 出错的配置项（json名称）
+	msg   string
 }
 
 func (e ErrInvalidConfig) Error() string {
 	return "invalid config: " + e.msg
 }
+
+// Field 返回出错的配置项名称
+func (e ErrInvalidConfig) Field() string {
+	return e.field
+}
+
+// ValidationErrors 配置校验发现的全部问题
+type ValidationErrors []ErrInvalidConfig
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.msg
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap 支持errors.Is/errors.As逐个检查
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
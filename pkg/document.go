@@ -0,0 +1,306 @@
+package elk_logger
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrFieldCollision 自定义字段与内置字段同名
+var ErrFieldCollision = errors.New("custom field collides with a reserved field")
+
+// FieldCollisionPolicy 自定义字段平铺到顶层时，与内置字段同名的处理方式
+type FieldCollisionPolicy string
+
+const (
+	CollisionPrefix    FieldCollisionPolicy = "prefix"    // 为冲突的键加上前缀后保留
+	CollisionReject    FieldCollisionPolicy = "reject"    // 拒绝整条日志，Log返回ErrFieldCollision
+	CollisionOverwrite FieldCollisionPolicy = "overwrite" // 覆盖内置字段（旧版本行为）
+)
+
+// valid 判断策略是否合法
+func (p FieldCollisionPolicy) valid() bool {
+	switch p {
+	case CollisionPrefix, CollisionReject, CollisionOverwrite:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultCollisionPrefix prefix策略的默认前缀
+const defaultCollisionPrefix = "custom_"
+
+// reservedNamespaces 不能用作自定义字段命名空间的顶层键
+var reservedNamespaces = []string{
+	"@timestamp", "message", "level", "logger", "caller", "stack", "environment",
+	"service", "host", "ecs", "log", "error", "truncated", "original_size",
+	"encoding_error", ecsMarkerNamespace,
+}
+
+// builtinField 内置字段在文档中的路径，用于判断自定义字段是否冲突
+type builtinField struct {
+	path    string
+	present func(*LogEntry) bool // 条目中是否写入该字段，为空时总是写入
+}
+
+// overlaps 判断自定义字段的键是否与内置字段占用相同位置
+// 与在文档中按occupied判断的结果一致：键与路径相同，或键位于不含点的内置字段之下；
+// nested为true时内置字段按路径嵌套，键位于内置字段之下或内置字段位于键之下都算冲突
+func (f builtinField) overlaps(key string, nested bool) bool {
+	if key == f.path {
+		return true
+	}
+	if nested {
+		return under(key, f.path) || under(f.path, key)
+	}
+	return !strings.Contains(f.path, ".") && under(key, f.path)
+}
+
+// under 判断点分路径key是否位于parent之下
+func under(key, parent string) bool {
+	return len(key) > len(parent) && key[len(parent)] == '.' && strings.HasPrefix(key, parent)
+}
+
+// legacyBuiltins legacy格式的内置字段，与legacyDocument写入的字段一致
+var legacyBuiltins = []builtinField{
+	{path: "@timestamp"},
+	{path: "level"},
+	{path: "message"},
+	{path: "logger", present: func(l *LogEntry) bool { return l.Logger != "" }},
+	{path: "caller", present: func(l *LogEntry) bool { return l.Caller != "" }},
+	{path: "stack", present: func(l *LogEntry) bool { return l.Stack != "" }},
+	{path: "service.name", present: func(l *LogEntry) bool { return l.ServiceName != "" }},
+	{path: "environment", present: func(l *LogEntry) bool { return l.Environment != "" }},
+	{path: "host.name", present: func(l *LogEntry) bool { return l.HostName != "" }},
+	{path: "host.ip", present: func(l *LogEntry) bool { return l.IP != "" }},
+	{path: "truncated", present: func(l *LogEntry) bool { return l.Truncated }},
+	{path: "original_size", present: func(l *LogEntry) bool { return l.Truncated }},
+	{path: "encoding_error", present: func(l *LogEntry) bool { return l.EncodingError != "" }},
+}
+
+// documentEncoder 按配置把日志条目编码为ES文档
+type documentEncoder struct {
+	format    DocumentFormat
+	namespace string               // 自定义字段嵌套到的顶层键，为空时平铺
+	collision FieldCollisionPolicy // 平铺时的冲突处理
+	prefix    string               // prefix策略使用的前缀
+	expand    bool                 // 是否将点分键展开为嵌套对象
+	maxDepth  int                  // 字段值最大嵌套层数，0表示不限制
+}
+
+// newDocumentEncoder 按配置创建文档编码器
+func newDocumentEncoder(config *Config) documentEncoder {
+	e := documentEncoder{
+		format:    config.DocumentFormat,
+		namespace: config.FieldsNamespace,
+		collision: config.FieldCollision,
+		prefix:    config.FieldCollisionPrefix,
+		expand:    config.ExpandDottedKeys,
+		maxDepth:  config.MaxFieldDepth,
+	}
+	if e.collision == "" {
+		e.collision = CollisionPrefix
+	}
+	if e.prefix == "" {
+		e.prefix = defaultCollisionPrefix
+	}
+	return e
+}
+
+// encode 编码日志条目
+func (e documentEncoder) encode(entry *LogEntry) ([]byte, error) {
+	b, err := e.appendDocument(make([]byte, 0, entry.EstimateSize()), entry)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// document 构建日志条目对应的文档
+func (e documentEncoder) document(entry *LogEntry) (map[string]interface{}, error) {
+	doc := make(map[string]interface{}, entry.fieldCount()+10)
+	if e.format == FormatECS {
+		entry = withECSError(entry)
+		entry.ecsDocument(doc)
+	} else {
+		e.legacyDocument(entry, doc)
+	}
+
+	fields, err := e.normalizeFields(entry.AllFields())
+	if err != nil {
+		return nil, err
+	}
+	if err := e.mergeFields(doc, fields); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// normalizeFields 返回字段值转换为可安全编码的值后的副本
+func (e documentEncoder) normalizeFields(fields Fields) (Fields, error) {
+	if len(fields) == 0 {
+		return fields, nil
+	}
+
+	normalized := make(Fields, len(fields))
+	for k, v := range fields {
+		value, err := normalizeValue(v, e.maxDepth)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", k, err)
+		}
+		normalized[k] = value
+	}
+	return normalized, nil
+}
+
+// checkFields 检查自定义字段是否与内置字段冲突，仅reject策略下有意义
+func (e documentEncoder) checkFields(entry *LogEntry) error {
+	if e.namespace != "" || e.collision != CollisionReject || entry.fieldCount() == 0 {
+		return nil
+	}
+	if e.expand {
+		// 编码错误在发送时处理，这里只关心冲突
+		if _, err := e.document(entry); errors.Is(err, ErrFieldCollision) {
+			return err
+		}
+		return nil
+	}
+
+	for k := range entry.fieldKeys() {
+		if e.collides(entry, k) {
+			return fmt.Errorf("%w: %s", ErrFieldCollision, k)
+		}
+	}
+	return nil
+}
+
+// legacyDocument 写入legacy格式的内置字段
+func (e documentEncoder) legacyDocument(l *LogEntry, doc map[string]interface{}) {
+	set := func(key string, value interface{}) {
+		e.put(doc, key, value, true)
+	}
+
+	set("@timestamp", l.Timestamp.Format(time.RFC3339Nano))
+	set("level", l.Level)
+	set("message", l.Message)
+
+	if l.Logger != "" {
+		set("logger", l.Logger)
+	}
+	if l.Caller != "" {
+		set("caller", l.Caller)
+	}
+	if l.Stack != "" {
+		set("stack", l.Stack)
+	}
+	if l.ServiceName != "" {
+		set("service.name", l.ServiceName)
+	}
+	if l.Environment != "" {
+		set("environment", l.Environment)
+	}
+	if l.HostName != "" {
+		set("host.name", l.HostName)
+	}
+	if l.IP != "" {
+		set("host.ip", l.IP)
+	}
+	if l.Truncated {
+		set("truncated", true)
+		set("original_size", l.OriginalSize)
+	}
+	if l.EncodingError != "" {
+		set("encoding_error", l.EncodingError)
+	}
+}
+
+// mergeFields 将自定义字段合并到文档
+// 设置命名空间时全部嵌套到该键下；否则平铺到顶层，并按冲突策略处理同名字段
+func (e documentEncoder) mergeFields(doc map[string]interface{}, fields Fields) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if e.namespace != "" {
+		nested := make(map[string]interface{}, len(fields))
+		for _, k := range slices.Sorted(maps.Keys(fields)) {
+			e.put(nested, k, fields[k], true)
+		}
+		doc[e.namespace] = nested
+		return nil
+	}
+
+	// 按键排序，保证冲突时的结果稳定
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		v := fields[k]
+		if e.put(doc, k, v, false) {
+			continue
+		}
+
+		switch e.collision {
+		case CollisionReject:
+			return fmt.Errorf("%w: %s", ErrFieldCollision, k)
+		case CollisionOverwrite:
+			e.put(doc, k, v, true)
+		default:
+			e.put(doc, e.prefix+k, v, true)
+		}
+	}
+	return nil
+}
+
+// put 写入字段，展开模式下点分键写入嵌套对象
+// force为false时，目标位置已被占用则不写入并返回false
+func (e documentEncoder) put(doc map[string]interface{}, key string, value interface{}, force bool) bool {
+	parts := strings.Split(key, ".")
+	if !e.expand || len(parts) == 1 || slices.Contains(parts, "") {
+		if !force && occupied(doc, key) {
+			return false
+		}
+		doc[key] = value
+		return true
+	}
+
+	m := doc
+	for _, part := range parts[:len(parts)-1] {
+		next, exists := m[part]
+		child, isMap := next.(map[string]interface{})
+		if !exists || (!isMap && force) {
+			child = make(map[string]interface{})
+			m[part] = child
+		} else if !isMap {
+			return false
+		}
+		m = child
+	}
+
+	leaf := parts[len(parts)-1]
+	if _, exists := m[leaf]; exists && !force {
+		return false
+	}
+	m[leaf] = value
+	return true
+}
+
+// occupied 判断键在文档中是否已存在，包括以点分路径表示的嵌套字段
+func occupied(doc map[string]interface{}, key string) bool {
+	if _, exists := doc[key]; exists {
+		return true
+	}
+
+	var cur interface{} = doc
+	for _, part := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return true
+		}
+		if cur, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package elk_logger
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ECSVersion ECS格式文档遵循的Elastic Common Schema版本
+const ECSVersion = "8.11.0"
+
+// ecsMarkerNamespace ECS格式下截断、编码错误等非ECS标记所在的顶层键
+const ecsMarkerNamespace = "elk_logger"
+
+// DocumentFormat 写入ES的文档格式
+type DocumentFormat string
+
+const (
+	FormatLegacy DocumentFormat = "legacy" // 平铺字段，如 level、service.name、caller
+	FormatECS    DocumentFormat = "ecs"    // Elastic Common Schema，可直接用于Kibana Logs UI和内置仪表盘
+)
+
+// valid 判断格式是否合法
+func (f DocumentFormat) valid() bool {
+	return f == FormatLegacy || f == FormatECS
+}
+
+// defaultEncoder 返回使用默认字段处理方式的编码器
+func (f DocumentFormat) defaultEncoder() documentEncoder {
+	return newDocumentEncoder(&Config{DocumentFormat: f, MaxFieldDepth: defaultMaxFieldDepth})
+}
+
+// ToECS 转换为符合ECS的JSON
+// 字段映射：level -> log.level，logger -> log.logger，caller -> log.origin.file.name/line，
+// stack -> error.stack_trace，environment -> service.environment，Err字段 -> error.message/error.type；
+// 截断和编码错误标记写入elk_logger.truncated、elk_logger.original_size和elk_logger.encoding_error。
+// 自定义字段平铺到顶层，与ECS字段同名时加上"custom_"前缀
+func (l *LogEntry) ToECS() ([]byte, error) {
+	return FormatECS.defaultEncoder().encode(l)
+}
+
+// ecsDocument 写入ECS格式的内置字段
+func (l *LogEntry) ecsDocument(data map[string]interface{}) {
+	data["@timestamp"] = l.Timestamp.Format(time.RFC3339Nano)
+	data["message"] = l.Message
+	data["ecs"] = map[string]interface{}{"version": ECSVersion}
+
+	log := map[string]interface{}{"level": l.Level}
+	if l.Logger != "" {
+		log["logger"] = l.Logger
+	}
+	if l.Caller != "" {
+		file := map[string]interface{}{}
+		name, line := splitCaller(l.Caller)
+		file["name"] = name
+		if line > 0 {
+			file["line"] = line
+		}
+		log["origin"] = map[string]interface{}{"file": file}
+	}
+	data["log"] = log
+
+	markers := map[string]interface{}{}
+	if l.Truncated {
+		markers["truncated"] = true
+		markers["original_size"] = l.OriginalSize
+	}
+	if l.EncodingError != "" {
+		markers["encoding_error"] = l.EncodingError
+	}
+	if len(markers) > 0 {
+		data[ecsMarkerNamespace] = markers
+	}
+
+	service := map[string]interface{}{}
+	if l.ServiceName != "" {
+		service["name"] = l.ServiceName
+	}
+	if l.Environment != "" {
+		service["environment"] = l.Environment
+	}
+	if len(service) > 0 {
+		data["service"] = service
+	}
+
+	host := map[string]interface{}{}
+	if l.HostName != "" {
+		host["name"] = l.HostName
+	}
+	if l.IP != "" {
+		host["ip"] = l.IP
+	}
+	if len(host) > 0 {
+		data["host"] = host
+	}
+
+	errObj := map[string]interface{}{}
+	if message, typ, ok := l.ecsError(); ok {
+		errObj["message"] = message
+		errObj["type"] = typ
+	}
+	if l.Stack != "" {
+		errObj["stack_trace"] = l.Stack
+	}
+	if len(errObj) > 0 {
+		data["error"] = errObj
+	}
+}
+
+// ecsBuiltins ECS格式的内置字段，与ecsDocument写入的字段一致
+var ecsBuiltins = []builtinField{
+	{path: "@timestamp"},
+	{path: "message"},
+	{path: "ecs.version"},
+	{path: "log.level"},
+	{path: "log.logger", present: func(l *LogEntry) bool { return l.Logger != "" }},
+	{path: "log.origin.file.name", present: func(l *LogEntry) bool { return l.Caller != "" }},
+	{path: "log.origin.file.line", present: func(l *LogEntry) bool {
+		_, line := splitCaller(l.Caller)
+		return line > 0
+	}},
+	{path: ecsMarkerNamespace + ".truncated", present: func(l *LogEntry) bool { return l.Truncated }},
+	{path: ecsMarkerNamespace + ".original_size", present: func(l *LogEntry) bool { return l.Truncated }},
+	{path: ecsMarkerNamespace + ".encoding_error", present: func(l *LogEntry) bool { return l.EncodingError != "" }},
+	{path: "service.name", present: func(l *LogEntry) bool { return l.ServiceName != "" }},
+	{path: "service.environment", present: func(l *LogEntry) bool { return l.Environment != "" }},
+	{path: "host.name", present: func(l *LogEntry) bool { return l.HostName != "" }},
+	{path: "host.ip", present: func(l *LogEntry) bool { return l.IP != "" }},
+	{path: "error.message", present: func(l *LogEntry) bool { return l.ecsErr.kind == kindError }},
+	{path: "error.type", present: func(l *LogEntry) bool { return l.ecsErr.kind == kindError }},
+	{path: "error.stack_trace", present: func(l *LogEntry) bool { return l.Stack != "" }},
+}
+
+// ecsError 返回移入error对象的Err字段的错误信息和类型
+func (l *LogEntry) ecsError() (message, typ string, ok bool) {
+	if l.ecsErr.kind != kindError {
+		return "", "", false
+	}
+	return l.ecsErr.errorMessage(), l.ecsErr.errorType(), true
+}
+
+// withECSError 返回将Err字段从自定义字段移入error对象的条目副本
+// ECS中error是对象，Err字段写为顶层字符串会与error.stack_trace冲突；没有Err字段时返回原条目
+func withECSError(entry *LogEntry) *LogEntry {
+	i := slices.IndexFunc(entry.TypedFields, func(f Field) bool {
+		return f.Key == "error" && f.kind == kindError
+	})
+	if i < 0 {
+		return entry
+	}
+
+	clone := *entry
+	clone.ecsErr = entry.TypedFields[i]
+	clone.TypedFields = slices.Delete(slices.Clone(entry.TypedFields), i, i+1)
+	return &clone
+}
+
+// splitCaller 将"file:line"形式的调用位置拆分为文件名和行号
+// 无法解析行号时返回完整字符串和0
+func splitCaller(caller string) (string, int) {
+	i := strings.LastIndexByte(caller, ':')
+	if i < 0 {
+		return caller, 0
+	}
+	line, err := strconv.Atoi(caller[i+1:])
+	if err != nil {
+		return caller, 0
+	}
+	return caller[:i], line
+}
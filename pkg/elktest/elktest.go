@@ -0,0 +1,461 @@
+// Package elktest 提供基于httptest的内存Elasticsearch假服务，用于离线测试Client和Sender
+//
+// 假服务响应集群信息（/）、批量写入（_bulk）、节点信息（_nodes/http）和模板API（_index_template、_template、_component_template），
+// 记录收到的文档，并可以按顺序为批量请求预设429、部分条目失败、延迟或断开连接等响应：
+//
+//	server := elktest.NewServer(t)
+//	server.Enqueue(elktest.Response{Status: http.StatusTooManyRequests})
+//	client, _ := elk.NewClient(server.NewConfig())
+package elktest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+// Version 假服务报告的Elasticsearch版本
+const Version = "8.11.0"
+
+// ItemError 批量响应中单个条目的错误
+type ItemError struct {
+	Status int    // 条目状态码
+	Type   string // 错误类型，如 es_rejected_execution_exception
+	Reason string // 错误原因
+}
+
+var (
+	// ItemRejected 集群繁忙拒绝写入，发送器会重发该条目
+	ItemRejected = ItemError{Status: http.StatusTooManyRequests, Type: "es_rejected_execution_exception", Reason: "rejected execution of coordinating operation"}
+	// ItemMappingError 字段映射冲突，不可重试
+	ItemMappingError = ItemError{Status: http.StatusBadRequest, Type: "mapper_parsing_exception", Reason: "failed to parse field"}
+)
+
+// Response 预设的批量请求响应
+type Response struct {
+	Status     int               // HTTP状态码，0表示200
+	RetryAfter string            // Retry-After响应头
+	Latency    time.Duration     // 返回响应前的等待时间，请求被取消时提前结束
+	Wait       <-chan struct{}   // 返回响应前等待通道关闭，用于让请求阻塞到测试放行为止
+	Reset      bool              // 不返回响应，直接断开连接
+	ItemErrors map[int]ItemError // 按请求中文档的序号（从0开始）设置失败的条目，其余条目写入成功
+}
+
+// Node 节点信息接口（_nodes/http）返回的节点，用于测试节点嗅探
+type Node struct {
+	Name    string   // 节点名
+	Roles   []string // 节点角色，为空表示仅协调节点
+	Address string   // HTTP发布地址，格式为host:port
+}
+
+// Document 写入成功的文档
+type Document struct {
+	Index  string                 // 索引名
+	ID     string                 // 假服务生成的文档ID
+	Source map[string]interface{} // 文档内容
+	Raw    json.RawMessage        // 原始文档
+}
+
+// Server 内存Elasticsearch假服务
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	script    []Response
+	fallback  Response
+	docs      []Document
+	bulks     int
+	nextID    int
+	templates map[string]json.RawMessage // 模板路径（如 _index_template/logs）-> 模板内容
+	nodes     []Node
+	notify    chan struct{}
+}
+
+// NewServer 启动假服务，测试结束时自动关闭
+func NewServer(tb testing.TB) *Server {
+	tb.Helper()
+
+	s := &Server{
+		templates: make(map[string]json.RawMessage),
+		notify:    make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// NewConfig 返回指向假服务的默认配置
+// 重试间隔缩短为毫秒级，使重试相关的测试快速完成
+func (s *Server) NewConfig() *elk.Config {
+	config := elk.DefaultConfig()
+	config.ESAddresses = []string{s.URL}
+	config.EnableHostInfo = false
+	config.RetryInterval = time.Millisecond
+	config.MaxRetryBackoff = 10 * time.Millisecond
+	return config
+}
+
+// Host 返回假服务的host:port，与节点指标和嗅探结果中的地址格式相同
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// SetNodes 设置节点信息接口返回的节点，默认不返回任何节点
+func (s *Server) SetNodes(nodes ...Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes = append([]Node(nil), nodes...)
+}
+
+// Enqueue 按顺序为之后的批量请求预设响应，预设的响应用完后使用SetDefault设置的响应
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, responses...)
+}
+
+// SetDefault 设置没有预设响应时的批量响应，如持续的延迟或故障，零值表示全部写入成功
+func (s *Server) SetDefault(response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = response
+}
+
+// Documents 返回写入成功的文档，按写入顺序排列
+func (s *Server) Documents() []Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Document(nil), s.docs...)
+}
+
+// Messages 返回写入成功的文档中的message字段
+func (s *Server) Messages() []string {
+	var messages []string
+	for _, doc := range s.Documents() {
+		message, _ := doc.Source["message"].(string)
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// BulkRequests 返回收到的批量请求数，包括预设为失败的请求
+func (s *Server) BulkRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bulks
+}
+
+// Template 返回通过模板API写入的模板，kind为 _index_template、_template 或 _component_template
+func (s *Server) Template(kind, name string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template, ok := s.templates[kind+"/"+name]
+	return template, ok
+}
+
+// Reset 清空收到的文档、计数和预设响应
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = nil
+	s.fallback = Response{}
+	s.docs = nil
+	s.bulks = 0
+}
+
+// WaitForDocuments 等待写入成功的文档达到n条，超时返回false
+func (s *Server) WaitForDocuments(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		count, notify := len(s.docs), s.notify
+		s.mu.Unlock()
+		if count >= n {
+			return true
+		}
+
+		select {
+		case <-notify:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// handle 按路径分发请求
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	// go-elasticsearch v8 检查此响应头确认连接的是Elasticsearch
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "":
+		s.handleInfo(w, r)
+	case path == "_bulk" || strings.HasSuffix(path, "/_bulk"):
+		s.handleBulk(w, r)
+	case path == "_nodes/http":
+		s.handleNodes(w)
+	case strings.HasPrefix(path, "_index_template/"),
+		strings.HasPrefix(path, "_template/"),
+		strings.HasPrefix(path, "_component_template/"):
+		s.handleTemplate(w, r, path)
+	default:
+		writeError(w, http.StatusNotFound, "resource_not_found_exception", "no handler found for "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// handleInfo 响应集群信息，Ping使用HEAD请求
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintf(w, `{"name":"elktest","cluster_name":"elktest","version":{"number":%q,"build_flavor":"default"},"tagline":"You Know, for Search"}`, Version)
+}
+
+// handleNodes 按Elasticsearch的格式返回节点信息
+func (s *Server) handleNodes(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nodes := make(map[string]interface{}, len(s.nodes))
+	for i, node := range s.nodes {
+		roles := node.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		nodes[fmt.Sprintf("elktest-node-%d", i)] = map[string]interface{}{
+			"name":  node.Name,
+			"roles": roles,
+			"http":  map[string]interface{}{"publish_address": node.Address},
+		}
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"nodes": nodes})
+}
+
+// handleTemplate 保存、读取和删除模板
+func (s *Server) handleTemplate(w http.ResponseWriter, r *http.Request, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		body, err := readBody(r)
+		if err != nil || !json.Valid(body) {
+			writeError(w, http.StatusBadRequest, "parse_exception", "request body is not valid JSON")
+			return
+		}
+		s.templates[path] = body
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case http.MethodDelete:
+		if _, ok := s.templates[path]; !ok {
+			writeError(w, http.StatusNotFound, "resource_not_found_exception", path+" not found")
+			return
+		}
+		delete(s.templates, path)
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	default:
+		template, ok := s.templates[path]
+		if !ok {
+			writeError(w, http.StatusNotFound, "resource_not_found_exception", path+" not found")
+			return
+		}
+		if r.Method == http.MethodGet {
+			writeTemplate(w, path, template)
+		}
+	}
+}
+
+// writeTemplate 按模板类型返回与Elasticsearch相同结构的响应
+func writeTemplate(w http.ResponseWriter, path string, template json.RawMessage) {
+	kind, name, _ := strings.Cut(path, "/")
+	switch kind {
+	case "_index_template":
+		fmt.Fprintf(w, `{"index_templates":[{"name":%q,"index_template":%s}]}`, name, template)
+	case "_component_template":
+		fmt.Fprintf(w, `{"component_templates":[{"name":%q,"component_template":%s}]}`, name, template)
+	default:
+		fmt.Fprintf(w, `{%q:%s}`, name, template)
+	}
+}
+
+// handleBulk 按预设响应处理批量请求
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parse_exception", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.bulks++
+	response := s.fallback
+	if len(s.script) > 0 {
+		response, s.script = s.script[0], s.script[1:]
+	}
+	s.mu.Unlock()
+
+	if response.Latency > 0 {
+		select {
+		case <-time.After(response.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if response.Wait != nil {
+		select {
+		case <-response.Wait:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if response.Reset {
+		resetConnection(w)
+		return
+	}
+
+	if response.RetryAfter != "" {
+		w.Header().Set("Retry-After", response.RetryAfter)
+	}
+	if response.Status != 0 && response.Status != http.StatusOK {
+		writeError(w, response.Status, "status_exception", http.StatusText(response.Status))
+		return
+	}
+
+	actions, err := parseBulk(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "illegal_argument_exception", err.Error())
+		return
+	}
+	_ = json.NewEncoder(w).Encode(s.index(actions, response.ItemErrors))
+}
+
+// bulkAction 批量请求中的一条写入操作
+type bulkAction struct {
+	op     string
+	index  string
+	source json.RawMessage
+}
+
+// index 写入文档并生成批量响应
+func (s *Server) index(actions []bulkAction, itemErrors map[int]ItemError) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]map[string]interface{}, 0, len(actions))
+	hasErrors := false
+	for i, action := range actions {
+		result := map[string]interface{}{"_index": action.index}
+
+		if itemErr, failed := itemErrors[i]; failed {
+			hasErrors = true
+			result["status"] = itemErr.Status
+			result["error"] = map[string]interface{}{"type": itemErr.Type, "reason": itemErr.Reason}
+		} else {
+			s.nextID++
+			doc := Document{Index: action.index, ID: fmt.Sprintf("elktest-%d", s.nextID), Raw: action.source}
+			_ = json.Unmarshal(action.source, &doc.Source)
+			s.docs = append(s.docs, doc)
+
+			result["_id"] = doc.ID
+			result["status"] = http.StatusCreated
+			result["result"] = "created"
+		}
+		items = append(items, map[string]interface{}{action.op: result})
+	}
+
+	// 唤醒等待文档的协程
+	close(s.notify)
+	s.notify = make(chan struct{})
+
+	return map[string]interface{}{"took": 1, "errors": hasErrors, "items": items}
+}
+
+// parseBulk 解析NDJSON格式的批量请求体，支持index和create操作
+func parseBulk(body []byte) ([]bulkAction, error) {
+	var actions []bulkAction
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(body)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var meta map[string]struct {
+			Index string `json:"_index"`
+		}
+		if err := json.Unmarshal(line, &meta); err != nil || len(meta) != 1 {
+			return nil, fmt.Errorf("malformed action/metadata line: %s", line)
+		}
+		var action bulkAction
+		for op, m := range meta {
+			action.op, action.index = op, m.Index
+		}
+		if action.op != "index" && action.op != "create" {
+			return nil, fmt.Errorf("unsupported bulk operation %q", action.op)
+		}
+
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing source for %s action", action.op)
+		}
+		source := bytes.TrimSpace(scanner.Bytes())
+		if !json.Valid(source) {
+			return nil, fmt.Errorf("malformed document: %s", source)
+		}
+		action.source = bytes.Clone(source)
+		actions = append(actions, action)
+	}
+	return actions, scanner.Err()
+}
+
+// readBody 读取请求体，支持gzip压缩
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	return io.ReadAll(reader)
+}
+
+// resetConnection 不返回响应直接关闭连接
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+// writeError 按Elasticsearch的格式返回错误
+func writeError(w http.ResponseWriter, status int, errType, reason string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  map[string]interface{}{"type": errType, "reason": reason},
+		"status": status,
+	})
+}
@@ -0,0 +1,417 @@
+package elk_logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 流式JSON编码
+// 文档直接追加写入字节切片，常见字段类型不经过反射和中间map，批量请求体由池化缓冲区组装。
+// 展开点分键、覆盖内置字段等少见情况回退到map编码，两种方式生成的文档内容一致
+
+// maxPooledBuffer 放回缓冲池的缓冲区最大容量，超大批次的缓冲区直接丢弃
+const maxPooledBuffer = 16 << 20
+
+// bufferPool 组装批量请求体的缓冲池
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 64<<10)
+		return &b
+	},
+}
+
+// getBuffer 从缓冲池获取空缓冲区
+func getBuffer() *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putBuffer 归还缓冲区
+func putBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// appendDocument 将日志条目编码后追加到b
+// 返回错误时b保持原长度
+func (e documentEncoder) appendDocument(b []byte, entry *LogEntry) ([]byte, error) {
+	if e.format == FormatECS {
+		entry = withECSError(entry)
+	}
+	if !e.streamable(entry) {
+		return e.appendMapDocument(b, entry)
+	}
+	if err := e.checkFields(entry); err != nil {
+		return b, err
+	}
+
+	start := len(b)
+	if e.format == FormatECS {
+		b = entry.appendECS(b)
+	} else {
+		b = entry.appendLegacy(b)
+	}
+
+	b, err := e.appendFields(b, entry)
+	if err != nil {
+		return b[:start], err
+	}
+	return append(b, '}'), nil
+}
+
+// streamable 判断能否流式编码
+// 展开点分键、强类型字段与其他字段同名，或自定义字段需要覆盖内置字段、加前缀后仍冲突时使用map编码
+func (e documentEncoder) streamable(entry *LogEntry) bool {
+	if e.expand || entry.duplicateKeys() {
+		return false
+	}
+	if e.namespace != "" || e.collision == CollisionReject {
+		return true
+	}
+
+	for k := range entry.fieldKeys() {
+		if !e.collides(entry, k) {
+			continue
+		}
+		if e.collision == CollisionOverwrite {
+			return false
+		}
+		prefixed := e.prefix + k
+		if entry.hasField(prefixed) || e.collides(entry, prefixed) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendMapDocument 构建map后使用encoding/json编码
+func (e documentEncoder) appendMapDocument(b []byte, entry *LogEntry) ([]byte, error) {
+	doc, err := e.document(entry)
+	if err != nil {
+		return b, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return b, err
+	}
+	return append(b, data...), nil
+}
+
+// collides 判断自定义字段在平铺时是否与内置字段冲突
+// 只有顶层键为保留名称的字段才需要与条目中存在的内置字段逐个比较，不构建文档
+func (e documentEncoder) collides(entry *LogEntry, key string) bool {
+	root, _, _ := strings.Cut(key, ".")
+	if !slices.Contains(reservedNamespaces, root) {
+		return false
+	}
+
+	nested := e.format == FormatECS
+	builtins := legacyBuiltins
+	if nested {
+		builtins = ecsBuiltins
+	}
+	for _, f := range builtins {
+		if f.overlaps(key, nested) && (f.present == nil || f.present(entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFields 追加自定义字段，调用前已确认冲突可以流式处理
+func (e documentEncoder) appendFields(b []byte, entry *LogEntry) ([]byte, error) {
+	if entry.fieldCount() == 0 {
+		return b, nil
+	}
+
+	// 命名空间下的字段不会与内置字段冲突
+	nested := e.namespace != ""
+	if nested {
+		b = append(b, ',')
+		b = appendString(b, e.namespace)
+		b = append(b, ':', '{')
+	}
+
+	var err error
+	first := true
+	field := func(key string) {
+		if !first || !nested {
+			b = append(b, ',')
+		}
+		first = false
+		if !nested && e.collides(entry, key) {
+			b = appendString(b, e.prefix+key)
+		} else {
+			b = appendString(b, key)
+		}
+		b = append(b, ':')
+	}
+
+	for k, v := range entry.Fields {
+		field(k)
+		if b, err = appendValue(b, v, e.maxDepth); err != nil {
+			return b, fmt.Errorf("field %q: %w", k, err)
+		}
+	}
+	for _, f := range entry.TypedFields {
+		field(f.Key)
+		if b, err = f.appendValue(b, e.maxDepth); err != nil {
+			return b, fmt.Errorf("field %q: %w", f.Key, err)
+		}
+	}
+
+	if nested {
+		b = append(b, '}')
+	}
+	return b, nil
+}
+
+// appendLegacy 追加legacy格式的内置字段，不包含结尾的 }
+func (l *LogEntry) appendLegacy(b []byte) []byte {
+	b = append(b, `{"@timestamp":"`...)
+	b = l.Timestamp.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","level":`...)
+	b = appendString(b, string(l.Level))
+	b = append(b, `,"message":`...)
+	b = appendString(b, l.Message)
+
+	b = appendOptional(b, "logger", l.Logger)
+	b = appendOptional(b, "caller", l.Caller)
+	b = appendOptional(b, "stack", l.Stack)
+	b = appendOptional(b, "service.name", l.ServiceName)
+	b = appendOptional(b, "environment", l.Environment)
+	b = appendOptional(b, "host.name", l.HostName)
+	b = appendOptional(b, "host.ip", l.IP)
+	if l.Truncated {
+		b = append(b, `,"truncated":true,"original_size":`...)
+		b = strconv.AppendInt(b, int64(l.OriginalSize), 10)
+	}
+	b = appendOptional(b, "encoding_error", l.EncodingError)
+	return b
+}
+
+// appendECS 追加ECS格式的内置字段，不包含结尾的 }
+func (l *LogEntry) appendECS(b []byte) []byte {
+	b = append(b, `{"@timestamp":"`...)
+	b = l.Timestamp.AppendFormat(b, time.RFC3339Nano)
+	b = append(b, `","message":`...)
+	b = appendString(b, l.Message)
+	b = append(b, `,"ecs":{"version":"`+ECSVersion+`"},"log":{"level":`...)
+	b = appendString(b, string(l.Level))
+	b = appendOptional(b, "logger", l.Logger)
+	if l.Caller != "" {
+		name, line := splitCaller(l.Caller)
+		b = append(b, `,"origin":{"file":{"name":`...)
+		b = appendString(b, name)
+		if line > 0 {
+			b = append(b, `,"line":`...)
+			b = strconv.AppendInt(b, int64(line), 10)
+		}
+		b = append(b, "}}"...)
+	}
+	b = append(b, '}')
+
+	if l.Truncated || l.EncodingError != "" {
+		b = append(b, `,"`+ecsMarkerNamespace+`":{`...)
+		if l.Truncated {
+			b = append(b, `"truncated":true,"original_size":`...)
+			b = strconv.AppendInt(b, int64(l.OriginalSize), 10)
+			b = appendOptional(b, "encoding_error", l.EncodingError)
+		} else {
+			b = append(b, `"encoding_error":`...)
+			b = appendString(b, l.EncodingError)
+		}
+		b = append(b, '}')
+	}
+
+	if l.ServiceName != "" || l.Environment != "" {
+		b = append(b, `,"service":{`...)
+		b = appendMembers(b, "name", l.ServiceName, "environment", l.Environment)
+		b = append(b, '}')
+	}
+	if l.HostName != "" || l.IP != "" {
+		b = append(b, `,"host":{`...)
+		b = appendMembers(b, "name", l.HostName, "ip", l.IP)
+		b = append(b, '}')
+	}
+	if message, typ, ok := l.ecsError(); ok {
+		b = append(b, `,"error":{"message":`...)
+		b = appendString(b, message)
+		b = append(b, `,"type":`...)
+		b = appendString(b, typ)
+		b = appendOptional(b, "stack_trace", l.Stack)
+		b = append(b, '}')
+	} else if l.Stack != "" {
+		b = append(b, `,"error":{"stack_trace":`...)
+		b = appendString(b, l.Stack)
+		b = append(b, '}')
+	}
+	return b
+}
+
+// appendOptional 值不为空时追加 ,"key":"value"
+func appendOptional(b []byte, key, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = append(b, ',', '"')
+	b = append(b, key...)
+	b = append(b, '"', ':')
+	return appendString(b, value)
+}
+
+// appendMembers 追加对象中的两个可选成员，至少一个不为空
+func appendMembers(b []byte, key1, value1, key2, value2 string) []byte {
+	if value1 != "" {
+		b = append(b, '"')
+		b = append(b, key1...)
+		b = append(b, '"', ':')
+		b = appendString(b, value1)
+		return appendOptional(b, key2, value2)
+	}
+	b = append(b, '"')
+	b = append(b, key2...)
+	b = append(b, '"', ':')
+	return appendString(b, value2)
+}
+
+// appendValue 追加字段值，常见类型直接编码，其他类型转换为可安全编码的值后使用encoding/json
+func appendValue(b []byte, value interface{}, maxDepth int) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendString(b, v), nil
+	case bool:
+		return strconv.AppendBool(b, v), nil
+	case int:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(b, v, 10), nil
+	case uint:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(b, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(b, v, 10), nil
+	case float64:
+		return appendFloat(b, v, 64), nil
+	case float32:
+		return appendFloat(b, float64(v), 32), nil
+	case time.Time:
+		if y := v.Year(); y >= 0 && y <= 9999 {
+			b = append(b, '"')
+			b = v.AppendFormat(b, time.RFC3339Nano)
+			return append(b, '"'), nil
+		}
+	case time.Duration:
+		return strconv.AppendInt(b, int64(v), 10), nil
+	}
+
+	normalized, err := normalizeValue(value, maxDepth)
+	if err != nil {
+		return b, err
+	}
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return b, err
+	}
+	return append(b, data...), nil
+}
+
+// appendFloat 按encoding/json的规则编码浮点数，NaN和Inf编码为null
+func appendFloat(b []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(b, "null"...)
+	}
+
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b = strconv.AppendFloat(b, f, format, -1, bits)
+	if format == 'e' {
+		// 1e-07 -> 1e-7，与encoding/json一致
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// hexDigits 转义控制字符使用的十六进制字符
+const hexDigits = "0123456789abcdef"
+
+// appendString 追加JSON字符串，非法UTF-8替换为U+FFFD
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028、U+2029在JavaScript中是换行符，与encoding/json一样转义
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
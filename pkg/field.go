@@ -0,0 +1,204 @@
+package elk_logger
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// fieldKind 强类型字段的值类型
+type fieldKind uint8
+
+const (
+	kindAny fieldKind = iota
+	kindString
+	kindInt
+	kindFloat
+	kindBool
+	kindDuration
+	kindTime
+	kindError
+)
+
+// Field 强类型日志字段
+// 值直接保存在结构体中，记录和编码时不经过map和反射
+type Field struct {
+	Key   string
+	kind  fieldKind
+	num   int64       // 整数、浮点数位、布尔值、时长或Unix纳秒时间；错误字段为1时表示错误信息已截断
+	str   string      // 字符串值，或截断后的错误信息
+	iface interface{} // 时间的Location、error或其他类型的值
+}
+
+// String 字符串字段
+func String(key, value string) Field {
+	return Field{Key: key, kind: kindString, str: value}
+}
+
+// Int 整数字段
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: kindInt, num: int64(value)}
+}
+
+// Int64 64位整数字段
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: kindInt, num: value}
+}
+
+// Float64 浮点数字段
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: kindFloat, num: int64(math.Float64bits(value))}
+}
+
+// Bool 布尔字段
+func Bool(key string, value bool) Field {
+	var num int64
+	if value {
+		num = 1
+	}
+	return Field{Key: key, kind: kindBool, num: num}
+}
+
+// Duration 时长字段，与Fields中的time.Duration一样编码为纳秒数
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: kindDuration, num: int64(value)}
+}
+
+// Time 时间字段，编码为RFC3339Nano格式
+func Time(key string, value time.Time) Field {
+	// UnixNano只能表示1678年到2262年之间的时间
+	if y := value.Year(); y < 1678 || y > 2261 {
+		return Any(key, value)
+	}
+	return Field{Key: key, kind: kindTime, num: value.UnixNano(), iface: value.Location()}
+}
+
+// Err 错误字段，键为"error"，值为err.Error()；err为nil时值为null
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error"}
+	}
+	return Field{Key: "error", kind: kindError, iface: err}
+}
+
+// Any 任意类型字段，编码方式与Fields中的值相同
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, iface: value}
+}
+
+// Value 返回字段值，与写入Fields时的值相同
+func (f Field) Value() interface{} {
+	switch f.kind {
+	case kindString:
+		return f.str
+	case kindInt:
+		return f.num
+	case kindFloat:
+		return math.Float64frombits(uint64(f.num))
+	case kindBool:
+		return f.num == 1
+	case kindDuration:
+		return time.Duration(f.num)
+	case kindTime:
+		return f.time()
+	case kindError:
+		return f.errorMessage()
+	default:
+		return f.iface
+	}
+}
+
+// errorMessage 返回错误字段的错误信息
+func (f Field) errorMessage() string {
+	if f.num == 1 {
+		return f.str
+	}
+	return f.iface.(error).Error()
+}
+
+// errorType 返回错误字段的错误类型名，如*fs.PathError
+func (f Field) errorType() string {
+	return fmt.Sprintf("%T", f.iface)
+}
+
+// truncate 返回字符串值截断到limit字节后的字段，错误字段保留错误类型
+func (f Field) truncate(limit int) Field {
+	s, _ := f.stringValue()
+	if f.kind == kindError {
+		return Field{Key: f.Key, kind: kindError, num: 1, str: truncateString(s, limit), iface: f.iface}
+	}
+	return String(f.Key, truncateString(s, limit))
+}
+
+// time 还原时间值
+func (f Field) time() time.Time {
+	t := time.Unix(0, f.num)
+	if loc, ok := f.iface.(*time.Location); ok {
+		t = t.In(loc)
+	}
+	return t
+}
+
+// stringValue 返回编码为JSON字符串的字段值，时间字段除外
+func (f Field) stringValue() (string, bool) {
+	switch f.kind {
+	case kindString:
+		return f.str, true
+	case kindError:
+		return f.errorMessage(), true
+	case kindAny:
+		s, ok := f.iface.(string)
+		return s, ok
+	default:
+		return "", false
+	}
+}
+
+// fieldType 字段在ES映射中的类型，用于字段类型保护
+func (f Field) fieldType() FieldType {
+	switch f.kind {
+	case kindString, kindTime, kindError:
+		return FieldTypeString
+	case kindInt, kindFloat, kindDuration:
+		return FieldTypeNumber
+	case kindBool:
+		return FieldTypeBoolean
+	default:
+		return fieldTypeOf(f.iface)
+	}
+}
+
+// appendValue 追加字段值的JSON编码，maxDepth为Any字段值的最大嵌套层数
+func (f Field) appendValue(b []byte, maxDepth int) ([]byte, error) {
+	switch f.kind {
+	case kindString:
+		return appendString(b, f.str), nil
+	case kindInt, kindDuration:
+		return strconv.AppendInt(b, f.num, 10), nil
+	case kindFloat:
+		return appendFloat(b, math.Float64frombits(uint64(f.num)), 64), nil
+	case kindBool:
+		return strconv.AppendBool(b, f.num == 1), nil
+	case kindTime:
+		b = append(b, '"')
+		b = f.time().AppendFormat(b, time.RFC3339Nano)
+		return append(b, '"'), nil
+	case kindError:
+		return appendString(b, f.errorMessage()), nil
+	default:
+		return appendValue(b, f.iface, maxDepth)
+	}
+}
+
+// estimateSize 估算字段值编码后的字节数
+func (f Field) estimateSize() int {
+	switch f.kind {
+	case kindString:
+		return len(f.str) + 2
+	case kindTime:
+		return 37
+	default:
+		return 16
+	}
+}
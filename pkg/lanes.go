@@ -0,0 +1,75 @@
+package elk_logger
+
+// 日志通道，按级别分类，数值越小优先级越高
+const (
+	laneHigh   = iota // error、fatal，使用预留容量
+	laneNormal        // info、warn
+	laneLow           // debug
+	laneCount
+)
+
+// laneOf 返回日志级别对应的通道
+func laneOf(level LogLevel) int {
+	switch {
+	case level.Priority() >= LevelError.Priority():
+		return laneHigh
+	case level == LevelDebug:
+		return laneLow
+	default:
+		return laneNormal
+	}
+}
+
+// newLanes 按配置创建各优先级通道
+func newLanes(config *Config) [laneCount]chan *LogEntry {
+	var lanes [laneCount]chan *LogEntry
+	lanes[laneHigh] = make(chan *LogEntry, config.HighPriorityQueueSize)
+	lanes[laneNormal] = make(chan *LogEntry, config.QueueSize)
+	lanes[laneLow] = make(chan *LogEntry, config.LowPriorityQueueSize)
+	return lanes
+}
+
+// laneFor 返回日志条目应进入的通道
+func (c *Client) laneFor(entry *LogEntry) chan *LogEntry {
+	return c.lanes[laneOf(entry.Level)]
+}
+
+// queueLen 返回所有通道中积压的日志数
+func (c *Client) queueLen() int {
+	n := 0
+	for _, lane := range c.lanes {
+		n += len(lane)
+	}
+	return n
+}
+
+// tryNext 按优先级从高到低非阻塞地取出一条日志
+func (c *Client) tryNext() (*LogEntry, bool) {
+	for _, lane := range c.lanes {
+		select {
+		case entry := <-lane:
+			return entry, true
+		default:
+		}
+	}
+	return nil, false
+}
+
+// next 取出下一条日志，优先处理高优先级通道
+// 所有通道为空时阻塞等待，客户端关闭时返回false
+func (c *Client) next() (*LogEntry, bool) {
+	if entry, ok := c.tryNext(); ok {
+		return entry, true
+	}
+
+	select {
+	case <-c.ctx.Done():
+		return nil, false
+	case entry := <-c.lanes[laneHigh]:
+		return entry, true
+	case entry := <-c.lanes[laneNormal]:
+		return entry, true
+	case entry := <-c.lanes[laneLow]:
+		return entry, true
+	}
+}
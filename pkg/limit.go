@@ -0,0 +1,81 @@
+package elk_logger
+
+// exceedsLimits 判断消息、堆栈或字符串字段是否超过配置的长度限制
+func exceedsLimits(config *Config, entry *LogEntry) bool {
+	over := func(s string, limit int) bool {
+		return limit > 0 && len(s) > limit
+	}
+
+	if over(entry.Message, config.MaxMessageBytes) || over(entry.Stack, config.MaxStackBytes) {
+		return true
+	}
+	if config.MaxFieldBytes <= 0 {
+		return false
+	}
+	for _, v := range entry.Fields {
+		if s, ok := v.(string); ok && over(s, config.MaxFieldBytes) {
+			return true
+		}
+	}
+	for _, f := range entry.TypedFields {
+		if s, ok := f.stringValue(); ok && over(s, config.MaxFieldBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitEntry 返回按长度限制截断后的条目副本，不修改entry
+// 截断按UTF-8字符边界进行，并在副本上记录截断标记和原始大小
+func limitEntry(config *Config, entry *LogEntry) *LogEntry {
+	clone := entry.Clone()
+	clone.markTruncated()
+
+	if limit := config.MaxMessageBytes; limit > 0 {
+		clone.Message = truncateString(clone.Message, limit)
+	}
+	if limit := config.MaxStackBytes; limit > 0 {
+		clone.Stack = truncateString(clone.Stack, limit)
+	}
+
+	limit := config.MaxFieldBytes
+	if limit <= 0 {
+		return clone
+	}
+	for k, v := range clone.Fields {
+		if s, ok := v.(string); ok && len(s) > limit {
+			clone.Fields[k] = truncateString(s, limit)
+		}
+	}
+	for i, f := range clone.TypedFields {
+		if s, ok := f.stringValue(); ok && len(s) > limit {
+			clone.TypedFields[i] = f.truncate(limit)
+		}
+	}
+	return clone
+}
+
+// markTruncated 标记条目已被截断，原始大小只在第一次截断时记录
+func (l *LogEntry) markTruncated() {
+	if l.Truncated {
+		return
+	}
+	l.Truncated = true
+	l.OriginalSize = l.contentSize()
+}
+
+// contentSize 消息、堆栈和字符串字段值的总字节数
+func (l *LogEntry) contentSize() int {
+	size := len(l.Message) + len(l.Stack)
+	for _, v := range l.Fields {
+		if s, ok := v.(string); ok {
+			size += len(s)
+		}
+	}
+	for _, f := range l.TypedFields {
+		if s, ok := f.stringValue(); ok {
+			size += len(s)
+		}
+	}
+	return size
+}
@@ -0,0 +1,377 @@
+package elk_logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix 环境变量覆盖配置的前缀
+// 变量名由配置键路径转换而来，如 batch.size 对应 ELK_BATCH_SIZE
+const envPrefix = "ELK_"
+
+// envProfile 指定环境配置段的环境变量
+const envProfile = "ELK_PROFILE"
+
+// ConfigKeyError 配置项错误
+type ConfigKeyError struct {
+	Key  string // 配置文件中的键路径（如 batch.size）或环境变量名
+	Line int    // 配置文件中的行号，0表示未知
+	Err  error
+}
+
+func (e *ConfigKeyError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %v", e.Key, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *ConfigKeyError) Unwrap() error {
+	return e.Err
+}
+
+// configKey 配置文件中的一个配置项
+type configKey struct {
+	path  string // 键路径，如 batch.size
+	field string // 对应Config字段的json名称，用于定位校验错误
+	list  bool   // 是否为列表，环境变量中以逗号分隔
+	set   func(c *Config, node *yaml.Node) error
+}
+
+// configKeys 配置文件支持的全部配置项，与config.example.yaml保持一致
+var configKeys = []configKey{
+	listKey("elasticsearch.addresses", "es_addresses", func(c *Config) *[]string { return &c.ESAddresses }),
+	valueKey("elasticsearch.username", "es_username", func(c *Config) *string { return &c.ESUsername }),
+	valueKey("elasticsearch.password", "es_password", func(c *Config) *string { return &c.ESPassword }),
+	valueKey("elasticsearch.password_file", "es_password_file", func(c *Config) *string { return &c.ESPasswordFile }),
+	valueKey("elasticsearch.api_key", "es_api_key", func(c *Config) *string { return &c.ESAPIKey }),
+	valueKey("elasticsearch.api_key_file", "es_api_key_file", func(c *Config) *string { return &c.ESAPIKeyFile }),
+	valueKey("elasticsearch.service_token", "es_service_token", func(c *Config) *string { return &c.ESServiceToken }),
+	valueKey("elasticsearch.service_token_file", "es_service_token_file", func(c *Config) *string { return &c.ESServiceTokenFile }),
+	valueKey("elasticsearch.cloud_id", "es_cloud_id", func(c *Config) *string { return &c.ESCloudID }),
+	valueKey("elasticsearch.ca_cert", "es_ca_cert", func(c *Config) *string { return &c.ESCACert }),
+	valueKey("elasticsearch.client_cert", "es_client_cert", func(c *Config) *string { return &c.ESClientCert }),
+	valueKey("elasticsearch.client_key", "es_client_key", func(c *Config) *string { return &c.ESClientKey }),
+	valueKey("elasticsearch.insecure_skip_verify", "es_insecure_skip_verify", func(c *Config) *bool { return &c.ESInsecureSkipVerify }),
+	valueKey("elasticsearch.index_pattern", "index_pattern", func(c *Config) *string { return &c.IndexPattern }),
+	valueKey("elasticsearch.sniffing", "enable_sniffing", func(c *Config) *bool { return &c.EnableSniffing }),
+	durationKey("elasticsearch.sniff_interval", "sniff_interval", func(c *Config) *time.Duration { return &c.SniffInterval }),
+	durationKey("elasticsearch.resurrect_timeout", "node_resurrect_timeout", func(c *Config) *time.Duration { return &c.NodeResurrectTimeout }),
+
+	valueKey("clusters.mode", "cluster_mode", func(c *Config) *ClusterMode { return &c.ClusterMode }),
+	valueKey("clusters.targets", "clusters", func(c *Config) *[]ClusterConfig { return &c.Clusters }),
+
+	valueKey("batch.size", "batch_size", func(c *Config) *int { return &c.BatchSize }),
+	durationKey("batch.timeout", "batch_timeout", func(c *Config) *time.Duration { return &c.BatchTimeout }),
+	durationKey("batch.flush_interval", "flush_interval", func(c *Config) *time.Duration { return &c.FlushInterval }),
+	valueKey("batch.max_bytes", "max_batch_bytes", func(c *Config) *int { return &c.MaxBatchBytes }),
+	valueKey("batch.max_document_bytes", "max_document_bytes", func(c *Config) *int { return &c.MaxDocumentBytes }),
+	valueKey("batch.max_message_bytes", "max_message_bytes", func(c *Config) *int { return &c.MaxMessageBytes }),
+	valueKey("batch.max_stack_bytes", "max_stack_bytes", func(c *Config) *int { return &c.MaxStackBytes }),
+	valueKey("batch.max_field_bytes", "max_field_bytes", func(c *Config) *int { return &c.MaxFieldBytes }),
+	valueKey("batch.truncate_oversize_doc", "truncate_oversize_doc", func(c *Config) *bool { return &c.TruncateOversizeDoc }),
+	valueKey("batch.document_format", "document_format", func(c *Config) *DocumentFormat { return &c.DocumentFormat }),
+	valueKey("fields.namespace", "fields_namespace", func(c *Config) *string { return &c.FieldsNamespace }),
+	valueKey("fields.collision", "field_collision", func(c *Config) *FieldCollisionPolicy { return &c.FieldCollision }),
+	valueKey("fields.collision_prefix", "field_collision_prefix", func(c *Config) *string { return &c.FieldCollisionPrefix }),
+	valueKey("fields.expand_dotted_keys", "expand_dotted_keys", func(c *Config) *bool { return &c.ExpandDottedKeys }),
+	valueKey("fields.max_depth", "max_field_depth", func(c *Config) *int { return &c.MaxFieldDepth }),
+	valueKey("fields.types", "field_types", func(c *Config) *map[string]FieldType { return &c.FieldTypes }),
+	valueKey("fields.infer_types", "infer_field_types", func(c *Config) *bool { return &c.InferFieldTypes }),
+	valueKey("fields.type_conflict", "type_conflict_policy", func(c *Config) *TypeConflictPolicy { return &c.TypeConflictPolicy }),
+	valueKey("batch.adaptive", "adaptive_batch", func(c *Config) *bool { return &c.AdaptiveBatch }),
+	valueKey("batch.min_size", "min_batch_size", func(c *Config) *int { return &c.MinBatchSize }),
+	valueKey("batch.max_size", "max_batch_size", func(c *Config) *int { return &c.MaxBatchSize }),
+	durationKey("batch.min_timeout", "min_batch_timeout", func(c *Config) *time.Duration { return &c.MinBatchTimeout }),
+	durationKey("batch.max_timeout", "max_batch_timeout", func(c *Config) *time.Duration { return &c.MaxBatchTimeout }),
+	durationKey("batch.target_latency", "target_latency", func(c *Config) *time.Duration { return &c.TargetLatency }),
+
+	valueKey("queue.size", "queue_size", func(c *Config) *int { return &c.QueueSize }),
+	valueKey("queue.high_priority_size", "high_priority_queue_size", func(c *Config) *int { return &c.HighPriorityQueueSize }),
+	valueKey("queue.low_priority_size", "low_priority_queue_size", func(c *Config) *int { return &c.LowPriorityQueueSize }),
+	valueKey("queue.flush_on_error", "flush_on_error", func(c *Config) *bool { return &c.FlushOnError }),
+	valueKey("queue.worker_count", "worker_count", func(c *Config) *int { return &c.WorkerCount }),
+	valueKey("queue.discard_on_full", "discard_on_full", func(c *Config) *bool { return &c.DiscardOnFull }),
+	valueKey("queue.full_policy", "queue_full_policy", func(c *Config) *QueueFullPolicy { return &c.QueueFullPolicy }),
+	durationKey("queue.enqueue_timeout", "enqueue_timeout", func(c *Config) *time.Duration { return &c.EnqueueTimeout }),
+	valueKey("queue.priority_drop_level", "priority_drop_level", func(c *Config) *LogLevel { return &c.PriorityDropLevel }),
+	valueKey("queue.sender_count", "sender_count", func(c *Config) *int { return &c.SenderCount }),
+	valueKey("queue.max_inflight_requests", "max_inflight_requests", func(c *Config) *int { return &c.MaxInflightRequests }),
+
+	valueKey("retry.count", "retry_count", func(c *Config) *int { return &c.RetryCount }),
+	durationKey("retry.interval", "retry_interval", func(c *Config) *time.Duration { return &c.RetryInterval }),
+	durationKey("retry.max_backoff", "max_retry_backoff", func(c *Config) *time.Duration { return &c.MaxRetryBackoff }),
+	durationKey("retry.request_timeout", "request_timeout", func(c *Config) *time.Duration { return &c.RequestTimeout }),
+	durationKey("retry.send_timeout", "send_timeout", func(c *Config) *time.Duration { return &c.SendTimeout }),
+
+	valueKey("circuit_breaker.threshold", "circuit_breaker_threshold", func(c *Config) *int { return &c.CircuitBreakerThreshold }),
+	durationKey("circuit_breaker.timeout", "circuit_breaker_timeout", func(c *Config) *time.Duration { return &c.CircuitBreakerTimeout }),
+
+	valueKey("application.service_name", "service_name", func(c *Config) *string { return &c.ServiceName }),
+	valueKey("application.environment", "environment", func(c *Config) *string { return &c.Environment }),
+	valueKey("application.enable_host_info", "enable_host_info", func(c *Config) *bool { return &c.EnableHostInfo }),
+	valueKey("application.min_level", "min_level", func(c *Config) *LogLevel { return &c.MinLevel }),
+	valueKey("application.sample_rate", "sample_rate", func(c *Config) *float64 { return &c.SampleRate }),
+	durationKey("application.fatal_flush_timeout", "fatal_flush_timeout", func(c *Config) *time.Duration { return &c.FatalFlushTimeout }),
+	valueKey("application.repanic_after_recover", "repanic_after_recover", func(c *Config) *bool { return &c.RepanicAfterRecover }),
+
+	valueKey("advanced.enable_compression", "enable_compression", func(c *Config) *bool { return &c.EnableCompression }),
+	durationKey("advanced.connection_timeout", "connection_timeout", func(c *Config) *time.Duration { return &c.ConnectionTimeout }),
+	durationKey("advanced.response_header_timeout", "response_header_timeout", func(c *Config) *time.Duration { return &c.ResponseHeaderTimeout }),
+	valueKey("advanced.max_idle_conns", "max_idle_conns", func(c *Config) *int { return &c.MaxIdleConns }),
+	valueKey("advanced.max_idle_conns_per_host", "max_idle_conns_per_host", func(c *Config) *int { return &c.MaxIdleConnsPerHost }),
+	valueKey("advanced.enable_debug", "enable_debug", func(c *Config) *bool { return &c.EnableDebug }),
+}
+
+// valueKey 创建普通类型的配置项
+func valueKey[T any](path, field string, ptr func(c *Config) *T) configKey {
+	return configKey{
+		path:  path,
+		field: field,
+		set: func(c *Config, node *yaml.Node) error {
+			return node.Decode(ptr(c))
+		},
+	}
+}
+
+// listKey 创建列表类型的配置项
+func listKey(path, field string, ptr func(c *Config) *[]string) configKey {
+	key := valueKey(path, field, ptr)
+	key.list = true
+	return key
+}
+
+// durationKey 创建时间类型的配置项
+// 数字按秒解析（与示例配置一致），字符串按time.ParseDuration解析，如 "5s"、"500ms"
+func durationKey(path, field string, ptr func(c *Config) *time.Duration) configKey {
+	return configKey{
+		path:  path,
+		field: field,
+		set: func(c *Config, node *yaml.Node) error {
+			if node.Kind != yaml.ScalarNode {
+				return errors.New("duration must be a number of seconds or a string like \"5s\"")
+			}
+			if seconds, err := strconv.ParseFloat(node.Value, 64); err == nil {
+				*ptr(c) = time.Duration(seconds * float64(time.Second))
+				return nil
+			}
+			d, err := time.ParseDuration(node.Value)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", node.Value)
+			}
+			*ptr(c) = d
+			return nil
+		},
+	}
+}
+
+// LoadConfig 从YAML或JSON文件加载配置
+// 文件结构与config.example.yaml一致，未出现的配置项使用DefaultConfig的值。
+// 环境配置段由ELK_PROFILE指定，未指定时使用application.environment，
+// 之后再应用ELK_*环境变量覆盖（如ELK_BATCH_SIZE、ELK_ELASTICSEARCH_ADDRESSES）
+func LoadConfig(path string) (*Config, error) {
+	return LoadConfigProfile(path, os.Getenv(envProfile))
+}
+
+// LoadConfigProfile 从文件加载配置并应用指定的环境配置段
+// profile为空时使用application.environment
+func LoadConfigProfile(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config, err := parseConfig(data, profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// parseConfig 解析配置内容并应用环境配置段和环境变量覆盖
+func parseConfig(data []byte, profile string) (*Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	lines := make(map[string]int)
+
+	var doc *yaml.Node
+	if len(root.Content) > 0 {
+		doc = root.Content[0]
+		if doc.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("line %d: config must be a mapping", doc.Line)
+		}
+	}
+
+	// 基础配置，其余顶层键视为环境配置段
+	profiles := make(map[string]*yaml.Node)
+	if doc != nil {
+		for i := 0; i+1 < len(doc.Content); i += 2 {
+			name, value := doc.Content[i], doc.Content[i+1]
+			if isSection(name.Value) {
+				if err := applySection(config, name.Value, value, lines); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if value.Kind != yaml.MappingNode {
+				return nil, &ConfigKeyError{Key: name.Value, Line: name.Line, Err: errors.New("unknown config section")}
+			}
+			profiles[name.Value] = value
+		}
+	}
+
+	// 所有环境配置段都做校验，避免拼写错误被静默忽略
+	for name, node := range profiles {
+		if err := applyProfile(DefaultConfig(), name, node, make(map[string]int)); err != nil {
+			return nil, err
+		}
+	}
+
+	if profile == "" {
+		profile = os.Getenv(envPrefix + "APPLICATION_ENVIRONMENT")
+	}
+	if profile == "" {
+		profile = config.Environment
+	}
+	if node, ok := profiles[profile]; ok {
+		if err := applyProfile(config, profile, node, lines); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, keyErrorFor(err, lines)
+	}
+
+	return config, nil
+}
+
+// applyProfile 应用环境配置段
+func applyProfile(config *Config, profile string, node *yaml.Node, lines map[string]int) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i], node.Content[i+1]
+		if !isSection(name.Value) {
+			return &ConfigKeyError{Key: profile + "." + name.Value, Line: name.Line, Err: errors.New("unknown config section")}
+		}
+		if err := applySection(config, name.Value, value, lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySection 应用一个配置段
+func applySection(config *Config, section string, node *yaml.Node, lines map[string]int) error {
+	if node.Kind != yaml.MappingNode {
+		// 空配置段
+		if node.Tag == "!!null" {
+			return nil
+		}
+		return &ConfigKeyError{Key: section, Line: node.Line, Err: errors.New("config section must be a mapping")}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i], node.Content[i+1]
+		path := section + "." + name.Value
+
+		key, ok := lookupKey(path)
+		if !ok {
+			return &ConfigKeyError{Key: path, Line: name.Line, Err: errors.New("unknown config key")}
+		}
+		if err := key.set(config, value); err != nil {
+			return &ConfigKeyError{Key: path, Line: value.Line, Err: err}
+		}
+		lines[path] = value.Line
+	}
+	return nil
+}
+
+// applyEnv 应用ELK_*环境变量覆盖
+func applyEnv(config *Config) error {
+	for _, key := range configKeys {
+		name := envName(key.path)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+		if key.list {
+			node = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+				}
+			}
+		}
+
+		if err := key.set(config, node); err != nil {
+			return &ConfigKeyError{Key: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// keyErrorFor 将校验错误逐个定位到配置文件中的键
+func keyErrorFor(err error, lines map[string]int) error {
+	var all ValidationErrors
+	if !errors.As(err, &all) {
+		return err
+	}
+
+	errs := make([]error, 0, len(all))
+	for _, invalid := range all {
+		errs = append(errs, keyErrorForField(invalid, lines))
+	}
+	return errors.Join(errs...)
+}
+
+// keyErrorForField 将单个配置项错误定位到配置文件中的键
+func keyErrorForField(invalid ErrInvalidConfig, lines map[string]int) error {
+	for _, key := range configKeys {
+		if key.field != "" && key.field == invalid.Field() {
+			return &ConfigKeyError{Key: key.path, Line: lines[key.path], Err: invalid}
+		}
+	}
+	return invalid
+}
+
+// lookupKey 按键路径查找配置项
+func lookupKey(path string) (configKey, bool) {
+	for _, key := range configKeys {
+		if key.path == path {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// isSection 判断是否为已知的配置段
+func isSection(name string) bool {
+	prefix := name + "."
+	for _, key := range configKeys {
+		if strings.HasPrefix(key.path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// envName 返回配置项对应的环境变量名
+func envName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
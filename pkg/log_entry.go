@@ -1,8 +1,11 @@
 package elk_logger
 
 import (
-	"encoding/json"
+	"iter"
+	"slices"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // LogLevel 日志级别
@@ -16,6 +19,33 @@ const (
 	LevelFatal LogLevel = "fatal"
 )
 
+// Priority 返回日志级别的优先级，级别越高数值越大
+// 未知级别按info处理
+func (l LogLevel) Priority() int {
+	switch l {
+	case LevelDebug:
+		return 0
+	case LevelWarn:
+		return 2
+	case LevelError:
+		return 3
+	case LevelFatal:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// valid 判断是否为已知的日志级别
+func (l LogLevel) valid() bool {
+	switch l {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal:
+		return true
+	default:
+		return false
+	}
+}
+
 // Fields 自定义字段类型
 type Fields map[string]interface{}
 
@@ -28,10 +58,21 @@ type LogEntry struct {
 	Caller      string    `json:"caller,omitempty"`    // 调用位置
 	Stack       string    `json:"stack,omitempty"`     // 堆栈信息（错误时）
 	Fields      Fields    `json:"fields,omitempty"`    // 自定义字段
+	TypedFields []Field   `json:"-"`                   // 强类型自定义字段，与Fields同名时以此为准
 	ServiceName string    `json:"service.name"`        // 服务名称
 	Environment string    `json:"environment"`         // 环境（dev/test/prod）
 	HostName    string    `json:"host.name,omitempty"` // 主机名
 	IP          string    `json:"host.ip,omitempty"`   // IP地址
+
+	Truncated    bool `json:"truncated,omitempty"`     // 消息、堆栈或字段值是否被截断
+	OriginalSize int  `json:"original_size,omitempty"` // 截断前消息、堆栈和字符串字段值的总字节数
+
+	EncodingError string `json:"encoding_error,omitempty"` // 自定义字段无法编码时的错误，此时文档不包含自定义字段
+
+	ecsErr Field // ECS格式下从自定义字段移入error对象的Err字段，只在编码使用的条目副本上设置
+
+	prepared bool   // 客户端是否已按大小限制处理并编码，已处理且document为空表示条目被拒绝
+	document []byte // 客户端处理后编码的文档，各集群的发送器直接使用
 }
 
 // NewLogEntry 创建新的日志条目
@@ -44,43 +85,168 @@ func NewLogEntry(level LogLevel, message string, fields Fields) *LogEntry {
 	}
 }
 
-// ToJSON 转换为JSON
-func (l *LogEntry) ToJSON() ([]byte, error) {
-	// 将自定义字段平铺到顶层
-	data := make(map[string]interface{})
+// entryPool 客户端内部使用的日志条目池
+// 客户端创建的条目在发送成功、FallbackHandler返回或被丢弃后回收，NewLogEntry创建的条目不经过池
+var entryPool = sync.Pool{
+	New: func() interface{} {
+		return new(LogEntry)
+	},
+}
 
-	data["@timestamp"] = l.Timestamp.Format(time.RFC3339Nano)
-	data["level"] = l.Level
-	data["message"] = l.Message
+// acquireEntry 从池中获取日志条目
+func acquireEntry(level LogLevel, message string) *LogEntry {
+	entry := entryPool.Get().(*LogEntry)
+	entry.Timestamp = time.Now()
+	entry.Level = level
+	entry.Message = message
+	return entry
+}
 
-	if l.Logger != "" {
-		data["logger"] = l.Logger
+// maxPooledDocument 随条目放回池中的文档缓冲区最大容量，超大文档的缓冲区直接丢弃
+const maxPooledDocument = 64 << 10
+
+// releaseEntry 清空日志条目并放回池中，调用后不能再使用entry
+// 条目自己的字段map、切片和文档缓冲区保留容量供下次使用
+func releaseEntry(entry *LogEntry) {
+	fields, typed, document := entry.Fields, entry.TypedFields, entry.document
+	clear(fields)
+	clear(typed)
+	if cap(document) > maxPooledDocument {
+		document = nil
 	}
-	if l.Caller != "" {
-		data["caller"] = l.Caller
+	*entry = LogEntry{Fields: fields, TypedFields: typed[:0], document: document[:0]}
+	entryPool.Put(entry)
+}
+
+// addFields 将字段复制到条目中，不保留调用方的map
+func (l *LogEntry) addFields(fields []Fields) {
+	for _, v := range fields {
+		if len(v) == 0 {
+			continue
+		}
+		if l.Fields == nil {
+			l.Fields = make(Fields, len(v))
+		}
+		for k, value := range v {
+			l.Fields[k] = value
+		}
 	}
-	if l.Stack != "" {
-		data["stack"] = l.Stack
+}
+
+// AllFields 返回合并Fields和TypedFields后的字段，同名时以TypedFields为准
+// 没有强类型字段时直接返回Fields
+func (l *LogEntry) AllFields() Fields {
+	if len(l.TypedFields) == 0 {
+		return l.Fields
 	}
-	if l.ServiceName != "" {
-		data["service.name"] = l.ServiceName
+
+	all := make(Fields, len(l.Fields)+len(l.TypedFields))
+	for k, v := range l.Fields {
+		all[k] = v
 	}
-	if l.Environment != "" {
-		data["environment"] = l.Environment
+	for _, f := range l.TypedFields {
+		all[f.Key] = f.Value()
 	}
-	if l.HostName != "" {
-		data["host.name"] = l.HostName
+	return all
+}
+
+// fieldCount 返回自定义字段数
+func (l *LogEntry) fieldCount() int {
+	return len(l.Fields) + len(l.TypedFields)
+}
+
+// fieldKeys 遍历所有自定义字段名
+func (l *LogEntry) fieldKeys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for k := range l.Fields {
+			if !yield(k) {
+				return
+			}
+		}
+		for _, f := range l.TypedFields {
+			if !yield(f.Key) {
+				return
+			}
+		}
 	}
-	if l.IP != "" {
-		data["host.ip"] = l.IP
+}
+
+// duplicateKeys 判断强类型字段之间或与Fields之间是否有同名字段
+func (l *LogEntry) duplicateKeys() bool {
+	for i, f := range l.TypedFields {
+		if _, ok := l.Fields[f.Key]; ok {
+			return true
+		}
+		for _, prev := range l.TypedFields[:i] {
+			if prev.Key == f.Key {
+				return true
+			}
+		}
 	}
+	return false
+}
+
+// hasField 判断是否存在同名的自定义字段
+func (l *LogEntry) hasField(key string) bool {
+	if _, ok := l.Fields[key]; ok {
+		return true
+	}
+	for _, f := range l.TypedFields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ToJSON 转换为JSON
+// 自定义字段平铺到顶层，与内置字段同名时加上"custom_"前缀
+func (l *LogEntry) ToJSON() ([]byte, error) {
+	return FormatLegacy.defaultEncoder().encode(l)
+}
+
+// entryBaseSize 文档固定部分（时间戳、级别及各字段名）的估算字节数
+const entryBaseSize = 128
+
+// EstimateSize 估算日志条目序列化后的字节数
+// 仅用于批次字节数控制，精确大小以发送时的编码结果为准
+func (l *LogEntry) EstimateSize() int {
+	size := entryBaseSize
+	size += len(l.Message) + len(l.Logger) + len(l.Caller) + len(l.Stack)
+	size += len(l.ServiceName) + len(l.Environment) + len(l.HostName) + len(l.IP)
 
-	// 合并自定义字段
 	for k, v := range l.Fields {
-		data[k] = v
+		size += len(k) + 4
+		switch val := v.(type) {
+		case string:
+			size += len(val) + 2
+		case []byte:
+			size += len(val)*4/3 + 2
+		default:
+			size += 16
+		}
 	}
+	for _, f := range l.TypedFields {
+		size += len(f.Key) + 4 + f.estimateSize()
+	}
+
+	return size
+}
 
-	return json.Marshal(data)
+// truncateString 按字节数截断字符串，保证不截断UTF-8字符
+func truncateString(s string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(s) <= maxBytes {
+		return s
+	}
+	// 回退到完整字符的起始位置
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
 }
 
 // Clone 克隆日志条目
@@ -92,5 +258,8 @@ func (l *LogEntry) Clone() *LogEntry {
 			clone.Fields[k] = v
 		}
 	}
+	clone.TypedFields = slices.Clone(l.TypedFields)
+	// 编码好的文档属于原条目，副本发送时重新编码
+	clone.prepared, clone.document = false, nil
 	return &clone
 }
@@ -1,6 +1,8 @@
 package elk_logger
 
 import (
+	"maps"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -8,12 +10,43 @@ import (
 // Metrics 监控指标
 type Metrics struct {
 	TotalLogs   int64 // 总日志数
-	SuccessLogs int64 // 成功发送数
-	FailedLogs  int64 // 失败数
+	SuccessLogs int64 // 成功发送的日志数
+	FailedLogs  int64 // 发送失败的日志数
 	DroppedLogs int64 // 丢弃数
 
+	BlockedLogs         int64 // 队列满时阻塞等待的次数
+	DroppedTimeoutLogs  int64 // 阻塞超时丢弃数
+	DroppedNewestLogs   int64 // 丢弃新日志数
+	DroppedOldestLogs   int64 // 丢弃最旧日志数
+	DroppedPriorityLogs int64 // 按优先级丢弃数
+
+	SampledLogs int64 // 采样丢弃数
+
+	TruncatedLogs  int64 // 超限截断数
+	RejectedLogs   int64 // 超限或字段冲突拒绝数
+	TypeConflicts  int64 // 字段类型冲突数
+	EncodingErrors int64 // 自定义字段无法编码的日志数
+
+	BatchSize    int64 // 当前批量大小
+	BatchTimeout int64 // 当前批量超时（纳秒）
+
+	CircuitState    int64 // 熔断器状态
+	CircuitOpens    int64 // 熔断次数
+	FallbackBatches int64 // 降级处理的批次数
+
+	PendingBatches int64 // 等待发送或发送中的批次数
+
 	totalLatency int64 // 总延迟（纳秒）
 	latencyCount int64 // 延迟计数
+
+	nodesMu sync.Mutex
+	nodes   map[string]*NodeSnapshot // 按节点地址统计的请求情况
+
+	clustersMu sync.Mutex
+	clusters   map[string]*ClusterSnapshot // 按集群统计的发送情况
+
+	typesMu       sync.Mutex
+	typeConflicts map[string]int64 // 按字段统计的类型冲突数
 }
 
 // NewMetrics 创建新的指标收集器
@@ -36,11 +69,94 @@ func (m *Metrics) IncFailed() {
 	atomic.AddInt64(&m.FailedLogs, 1)
 }
 
+// AddSuccess 增加成功发送的日志数
+func (m *Metrics) AddSuccess(n int) {
+	atomic.AddInt64(&m.SuccessLogs, int64(n))
+}
+
+// AddFailed 增加发送失败的日志数
+func (m *Metrics) AddFailed(n int) {
+	atomic.AddInt64(&m.FailedLogs, int64(n))
+}
+
 // IncDropped 增加丢弃数
 func (m *Metrics) IncDropped() {
 	atomic.AddInt64(&m.DroppedLogs, 1)
 }
 
+// IncBlocked 增加队列满阻塞等待次数
+func (m *Metrics) IncBlocked() {
+	atomic.AddInt64(&m.BlockedLogs, 1)
+}
+
+// IncDroppedTimeout 增加阻塞超时丢弃数
+func (m *Metrics) IncDroppedTimeout() {
+	atomic.AddInt64(&m.DroppedTimeoutLogs, 1)
+	m.IncDropped()
+}
+
+// IncDroppedNewest 增加丢弃新日志数
+func (m *Metrics) IncDroppedNewest() {
+	atomic.AddInt64(&m.DroppedNewestLogs, 1)
+	m.IncDropped()
+}
+
+// IncDroppedOldest 增加丢弃最旧日志数
+func (m *Metrics) IncDroppedOldest() {
+	atomic.AddInt64(&m.DroppedOldestLogs, 1)
+	m.IncDropped()
+}
+
+// IncDroppedPriority 增加按优先级丢弃数
+func (m *Metrics) IncDroppedPriority() {
+	atomic.AddInt64(&m.DroppedPriorityLogs, 1)
+	m.IncDropped()
+}
+
+// IncSampled 增加采样丢弃数
+func (m *Metrics) IncSampled() {
+	atomic.AddInt64(&m.SampledLogs, 1)
+}
+
+// IncTruncated 增加超限截断数
+func (m *Metrics) IncTruncated() {
+	atomic.AddInt64(&m.TruncatedLogs, 1)
+}
+
+// IncRejected 增加超限或字段冲突拒绝数
+func (m *Metrics) IncRejected() {
+	atomic.AddInt64(&m.RejectedLogs, 1)
+}
+
+// IncEncodingError 增加自定义字段无法编码的日志数
+func (m *Metrics) IncEncodingError() {
+	atomic.AddInt64(&m.EncodingErrors, 1)
+}
+
+// SetBatchParams 记录当前批量参数
+func (m *Metrics) SetBatchParams(size int, timeout time.Duration) {
+	atomic.StoreInt64(&m.BatchSize, int64(size))
+	atomic.StoreInt64(&m.BatchTimeout, int64(timeout))
+}
+
+// SetCircuitState 记录熔断器状态，进入打开状态时累加熔断次数
+func (m *Metrics) SetCircuitState(state CircuitState) {
+	atomic.StoreInt64(&m.CircuitState, int64(state))
+	if state == CircuitOpen {
+		atomic.AddInt64(&m.CircuitOpens, 1)
+	}
+}
+
+// IncFallback 增加降级处理的批次数
+func (m *Metrics) IncFallback() {
+	atomic.AddInt64(&m.FallbackBatches, 1)
+}
+
+// SetPendingBatches 记录等待发送或发送中的批次数
+func (m *Metrics) SetPendingBatches(n int) {
+	atomic.StoreInt64(&m.PendingBatches, int64(n))
+}
+
 // RecordLatency 记录延迟
 func (m *Metrics) RecordLatency(latency time.Duration) {
 	atomic.AddInt64(&m.totalLatency, int64(latency))
@@ -65,6 +181,32 @@ func (m *Metrics) Snapshot() MetricsSnapshot {
 		FailedLogs:  atomic.LoadInt64(&m.FailedLogs),
 		DroppedLogs: atomic.LoadInt64(&m.DroppedLogs),
 		AvgLatency:  m.GetAvgLatency(),
+
+		BlockedLogs:         atomic.LoadInt64(&m.BlockedLogs),
+		DroppedTimeoutLogs:  atomic.LoadInt64(&m.DroppedTimeoutLogs),
+		DroppedNewestLogs:   atomic.LoadInt64(&m.DroppedNewestLogs),
+		DroppedOldestLogs:   atomic.LoadInt64(&m.DroppedOldestLogs),
+		DroppedPriorityLogs: atomic.LoadInt64(&m.DroppedPriorityLogs),
+
+		SampledLogs: atomic.LoadInt64(&m.SampledLogs),
+
+		TruncatedLogs:  atomic.LoadInt64(&m.TruncatedLogs),
+		RejectedLogs:   atomic.LoadInt64(&m.RejectedLogs),
+		TypeConflicts:  atomic.LoadInt64(&m.TypeConflicts),
+		EncodingErrors: atomic.LoadInt64(&m.EncodingErrors),
+
+		BatchSize:    atomic.LoadInt64(&m.BatchSize),
+		BatchTimeout: atomic.LoadInt64(&m.BatchTimeout) / int64(time.Millisecond),
+
+		CircuitState:    CircuitState(atomic.LoadInt64(&m.CircuitState)).String(),
+		CircuitOpens:    atomic.LoadInt64(&m.CircuitOpens),
+		FallbackBatches: atomic.LoadInt64(&m.FallbackBatches),
+
+		PendingBatches: atomic.LoadInt64(&m.PendingBatches),
+
+		Nodes:              m.nodeSnapshots(),
+		Clusters:           m.clusterSnapshots(),
+		TypeConflictFields: m.typeConflictSnapshots(),
 	}
 }
 
@@ -75,6 +217,164 @@ type MetricsSnapshot struct {
 	FailedLogs  int64 `json:"failed_logs"`
 	DroppedLogs int64 `json:"dropped_logs"`
 	AvgLatency  int64 `json:"avg_latency_ms"`
+
+	BlockedLogs         int64 `json:"blocked_logs"`
+	DroppedTimeoutLogs  int64 `json:"dropped_timeout_logs"`
+	DroppedNewestLogs   int64 `json:"dropped_newest_logs"`
+	DroppedOldestLogs   int64 `json:"dropped_oldest_logs"`
+	DroppedPriorityLogs int64 `json:"dropped_priority_logs"`
+
+	SampledLogs int64 `json:"sampled_logs"`
+
+	TruncatedLogs  int64 `json:"truncated_logs"`
+	RejectedLogs   int64 `json:"rejected_logs"`
+	TypeConflicts  int64 `json:"type_conflicts"`
+	EncodingErrors int64 `json:"encoding_errors"`
+
+	BatchSize    int64 `json:"batch_size"`
+	BatchTimeout int64 `json:"batch_timeout_ms"`
+
+	CircuitState    string `json:"circuit_state"`
+	CircuitOpens    int64  `json:"circuit_opens"`
+	FallbackBatches int64  `json:"fallback_batches"`
+
+	PendingBatches int64 `json:"pending_batches"`
+
+	Nodes              map[string]NodeSnapshot    `json:"nodes,omitempty"`
+	Clusters           map[string]ClusterSnapshot `json:"clusters,omitempty"`
+	TypeConflictFields map[string]int64           `json:"type_conflict_fields,omitempty"` // 按字段统计的类型冲突数
+}
+
+// RecordTypeConflict 记录一次字段类型冲突
+func (m *Metrics) RecordTypeConflict(field string) {
+	atomic.AddInt64(&m.TypeConflicts, 1)
+
+	m.typesMu.Lock()
+	defer m.typesMu.Unlock()
+	if m.typeConflicts == nil {
+		m.typeConflicts = make(map[string]int64)
+	}
+	m.typeConflicts[field]++
+}
+
+// typeConflictSnapshots 复制按字段统计的类型冲突数
+func (m *Metrics) typeConflictSnapshots() map[string]int64 {
+	m.typesMu.Lock()
+	defer m.typesMu.Unlock()
+
+	if len(m.typeConflicts) == 0 {
+		return nil
+	}
+	return maps.Clone(m.typeConflicts)
+}
+
+// ClusterSnapshot 单个集群的发送统计，只在配置了备用集群时记录
+type ClusterSnapshot struct {
+	SuccessLogs  int64  `json:"success_logs"`  // 发送成功的日志数
+	FailedLogs   int64  `json:"failed_logs"`   // 发送失败或被熔断的日志数
+	CircuitState string `json:"circuit_state"` // 熔断器状态
+}
+
+// RecordClusterSend 记录一次发往集群的批次
+func (m *Metrics) RecordClusterSend(cluster string, logs int, err error) {
+	m.clustersMu.Lock()
+	defer m.clustersMu.Unlock()
+
+	stats := m.cluster(cluster)
+	if err != nil {
+		stats.FailedLogs += int64(logs)
+	} else {
+		stats.SuccessLogs += int64(logs)
+	}
+}
+
+// SetClusterCircuitState 设置集群熔断器状态
+func (m *Metrics) SetClusterCircuitState(cluster string, state CircuitState) {
+	m.clustersMu.Lock()
+	defer m.clustersMu.Unlock()
+	m.cluster(cluster).CircuitState = state.String()
+}
+
+// cluster 返回集群统计，调用方需持有clustersMu
+func (m *Metrics) cluster(cluster string) *ClusterSnapshot {
+	if m.clusters == nil {
+		m.clusters = make(map[string]*ClusterSnapshot)
+	}
+	stats, ok := m.clusters[cluster]
+	if !ok {
+		stats = &ClusterSnapshot{CircuitState: CircuitClosed.String()}
+		m.clusters[cluster] = stats
+	}
+	return stats
+}
+
+// clusterSnapshots 复制集群统计
+func (m *Metrics) clusterSnapshots() map[string]ClusterSnapshot {
+	m.clustersMu.Lock()
+	defer m.clustersMu.Unlock()
+
+	if len(m.clusters) == 0 {
+		return nil
+	}
+	clusters := make(map[string]ClusterSnapshot, len(m.clusters))
+	for name, stats := range m.clusters {
+		clusters[name] = *stats
+	}
+	return clusters
+}
+
+// NodeSnapshot 单个ES节点的请求统计
+type NodeSnapshot struct {
+	Requests int64 `json:"requests"` // 请求数
+	Failures int64 `json:"failures"` // 失败的请求数（5xx、超时、网络错误）
+	Dead     bool  `json:"dead"`     // 当前是否被标记为不可用
+}
+
+// RecordNodeRequest 记录一次发往节点的请求
+func (m *Metrics) RecordNodeRequest(node string, failed bool) {
+	m.nodesMu.Lock()
+	defer m.nodesMu.Unlock()
+
+	stats := m.node(node)
+	stats.Requests++
+	if failed {
+		stats.Failures++
+	}
+}
+
+// SetNodeDead 设置节点是否不可用
+func (m *Metrics) SetNodeDead(node string, dead bool) {
+	m.nodesMu.Lock()
+	defer m.nodesMu.Unlock()
+	m.node(node).Dead = dead
+}
+
+// node 返回节点统计，调用方需持有nodesMu
+func (m *Metrics) node(node string) *NodeSnapshot {
+	if m.nodes == nil {
+		m.nodes = make(map[string]*NodeSnapshot)
+	}
+	stats, ok := m.nodes[node]
+	if !ok {
+		stats = &NodeSnapshot{}
+		m.nodes[node] = stats
+	}
+	return stats
+}
+
+// nodeSnapshots 复制节点统计
+func (m *Metrics) nodeSnapshots() map[string]NodeSnapshot {
+	m.nodesMu.Lock()
+	defer m.nodesMu.Unlock()
+
+	if len(m.nodes) == 0 {
+		return nil
+	}
+	nodes := make(map[string]NodeSnapshot, len(m.nodes))
+	for name, stats := range m.nodes {
+		nodes[name] = *stats
+	}
+	return nodes
 }
 
 // Reset 重置指标
@@ -83,6 +383,37 @@ func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.SuccessLogs, 0)
 	atomic.StoreInt64(&m.FailedLogs, 0)
 	atomic.StoreInt64(&m.DroppedLogs, 0)
+	atomic.StoreInt64(&m.BlockedLogs, 0)
+	atomic.StoreInt64(&m.DroppedTimeoutLogs, 0)
+	atomic.StoreInt64(&m.DroppedNewestLogs, 0)
+	atomic.StoreInt64(&m.DroppedOldestLogs, 0)
+	atomic.StoreInt64(&m.DroppedPriorityLogs, 0)
+	atomic.StoreInt64(&m.SampledLogs, 0)
+	atomic.StoreInt64(&m.TruncatedLogs, 0)
+	atomic.StoreInt64(&m.RejectedLogs, 0)
+	atomic.StoreInt64(&m.TypeConflicts, 0)
+	atomic.StoreInt64(&m.EncodingErrors, 0)
+	atomic.StoreInt64(&m.CircuitOpens, 0)
+	atomic.StoreInt64(&m.FallbackBatches, 0)
 	atomic.StoreInt64(&m.totalLatency, 0)
 	atomic.StoreInt64(&m.latencyCount, 0)
+
+	// 节点可用状态和熔断状态不重置
+	m.nodesMu.Lock()
+	for _, stats := range m.nodes {
+		stats.Requests = 0
+		stats.Failures = 0
+	}
+	m.nodesMu.Unlock()
+
+	m.clustersMu.Lock()
+	for _, stats := range m.clusters {
+		stats.SuccessLogs = 0
+		stats.FailedLogs = 0
+	}
+	m.clustersMu.Unlock()
+
+	m.typesMu.Lock()
+	m.typeConflicts = nil
+	m.typesMu.Unlock()
 }
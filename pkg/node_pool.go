@@ -0,0 +1,212 @@
+package elk_logger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+)
+
+// maxResurrectShift 节点连续失败时复活等待时间最多翻倍的次数
+const maxResurrectShift = 5
+
+// nodePool 感知节点健康状态的连接池
+// 优先选择仅协调节点，其次是ingest节点；返回5xx、超时或网络错误的节点
+// 被标记为不可用，等待时间到期后重新尝试，连续失败时等待时间翻倍
+type nodePool struct {
+	mu               sync.Mutex
+	nodes            []*poolNode
+	cursor           int
+	resurrectTimeout time.Duration
+	metrics          *Metrics
+}
+
+// poolNode 连接池中的节点
+type poolNode struct {
+	conn      *elastictransport.Connection
+	failures  int       // 连续失败次数
+	deadUntil time.Time // 不可用截止时间，零值表示可用
+}
+
+// newNodePool 创建空连接池，节点由Update填充
+func newNodePool(resurrectTimeout time.Duration, metrics *Metrics) *nodePool {
+	return &nodePool{
+		resurrectTimeout: resurrectTimeout,
+		metrics:          metrics,
+	}
+}
+
+// Next 选择下一个节点
+// 没有可用节点时返回最早到期的不可用节点，让请求有机会复活它
+func (p *nodePool) Next() (*elastictransport.Connection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.nodes) == 0 {
+		return nil, errors.New("no elasticsearch node available")
+	}
+
+	now := time.Now()
+	var preferred, others []*poolNode
+	var earliest *poolNode
+	for _, node := range p.nodes {
+		if node.deadUntil.After(now) {
+			if earliest == nil || node.deadUntil.Before(earliest.deadUntil) {
+				earliest = node
+			}
+			continue
+		}
+		if isCoordinatingOnly(node.conn) {
+			preferred = append(preferred, node)
+		} else {
+			others = append(others, node)
+		}
+	}
+
+	candidates := preferred
+	if len(candidates) == 0 {
+		candidates = others
+	}
+	if len(candidates) == 0 {
+		return earliest.conn, nil
+	}
+
+	p.cursor++
+	return candidates[p.cursor%len(candidates)].conn, nil
+}
+
+// OnSuccess 节点健康状态由nodeTracker根据响应状态码判断，这里不做处理
+// 传输层对5xx响应也会调用OnSuccess
+func (p *nodePool) OnSuccess(conn *elastictransport.Connection) error {
+	return nil
+}
+
+// OnFailure 节点健康状态由nodeTracker判断，这里不做处理
+func (p *nodePool) OnFailure(conn *elastictransport.Connection) error {
+	return nil
+}
+
+// URLs 返回所有节点地址
+func (p *nodePool) URLs() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	urls := make([]*url.URL, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		urls = append(urls, node.conn.URL)
+	}
+	return urls
+}
+
+// Update 使用嗅探到的节点替换节点列表，只保留可以接收写入的节点
+// 已存在节点的健康状态会被保留
+func (p *nodePool) Update(conns []*elastictransport.Connection) error {
+	ingest := make([]*elastictransport.Connection, 0, len(conns))
+	for _, conn := range conns {
+		if canIngest(conn) {
+			ingest = append(ingest, conn)
+		}
+	}
+	if len(ingest) == 0 {
+		ingest = conns
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nodes := make([]*poolNode, 0, len(ingest))
+	for _, conn := range ingest {
+		node := &poolNode{conn: conn}
+		if prev := p.find(conn.URL.Host); prev != nil {
+			node.failures = prev.failures
+			node.deadUntil = prev.deadUntil
+		}
+		nodes = append(nodes, node)
+	}
+	p.nodes = nodes
+	return nil
+}
+
+// markDead 标记节点不可用
+// 节点已处于不可用期间时忽略，避免同一次失败被重复计算
+func (p *nodePool) markDead(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node := p.find(host)
+	if node == nil || node.deadUntil.After(time.Now()) {
+		return
+	}
+
+	node.failures++
+	factor := time.Duration(1) << min(node.failures-1, maxResurrectShift)
+	node.deadUntil = time.Now().Add(p.resurrectTimeout * factor)
+	p.metrics.SetNodeDead(host, true)
+}
+
+// markAlive 标记节点可用
+func (p *nodePool) markAlive(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	node := p.find(host)
+	if node == nil || (node.failures == 0 && node.deadUntil.IsZero()) {
+		return
+	}
+
+	node.failures = 0
+	node.deadUntil = time.Time{}
+	p.metrics.SetNodeDead(host, false)
+}
+
+// find 按地址查找节点，调用方需持有锁
+func (p *nodePool) find(host string) *poolNode {
+	for _, node := range p.nodes {
+		if node.conn.URL.Host == host {
+			return node
+		}
+	}
+	return nil
+}
+
+// isCoordinatingOnly 判断是否为嗅探到的仅协调节点（没有任何角色）
+func isCoordinatingOnly(conn *elastictransport.Connection) bool {
+	return conn.ID != "" && len(conn.Roles) == 0
+}
+
+// canIngest 判断节点是否可以接收写入：仅协调节点或带ingest角色的节点
+func canIngest(conn *elastictransport.Connection) bool {
+	return len(conn.Roles) == 0 || slices.Contains(conn.Roles, "ingest")
+}
+
+// nodeTracker 统计每个节点的请求数和失败数，并更新连接池中的节点健康状态
+// 返回5xx、超时或网络错误的节点被标记为不可用，成功响应则恢复节点
+type nodeTracker struct {
+	next    http.RoundTripper
+	pool    *nodePool
+	metrics *Metrics
+}
+
+func (t *nodeTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	res, err := t.next.RoundTrip(req)
+
+	// 调用方主动取消不代表节点异常
+	if errors.Is(err, context.Canceled) {
+		return res, err
+	}
+
+	failed := err != nil || res.StatusCode >= http.StatusInternalServerError
+	t.metrics.RecordNodeRequest(host, failed)
+	if failed {
+		t.pool.markDead(host)
+	} else {
+		t.pool.markAlive(host)
+	}
+	return res, err
+}
@@ -0,0 +1,395 @@
+package elk_logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultMaxFieldDepth 自定义字段值的默认最大嵌套层数
+const defaultMaxFieldDepth = 10
+
+// normalizer 将任意字段值转换为可安全编码为JSON的值
+// 结构体按json标签转为map，error和结构体上的fmt.Stringer转为字符串，json.Marshaler和encoding.TextMarshaler优先；
+// NaN、Inf、循环引用以及超过最大深度的值写为null，避免与字段映射冲突
+// 通道、函数、复数等无法表示的类型以及Marshaler返回的错误作为编码错误返回
+type normalizer struct {
+	maxDepth int                // 最大嵌套层数，0表示不限制
+	visited  map[visit]struct{} // 当前路径上的指针、map和切片，用于检测循环引用
+}
+
+// visit 引用的标识，切片的起始地址可能与其他引用相同，需要同时比较类型和长度
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// normalizeValue 转换字段值，maxDepth为0时不限制深度
+func normalizeValue(value interface{}, maxDepth int) (interface{}, error) {
+	n := normalizer{maxDepth: maxDepth}
+	return n.value(reflect.ValueOf(value), 0)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	errorType         = reflect.TypeFor[error]()
+	numberType        = reflect.TypeFor[json.Number]()
+	stringerType      = reflect.TypeFor[fmt.Stringer]()
+)
+
+// value 转换单个值，depth为已经过的对象和数组层数
+func (n *normalizer) value(v reflect.Value, depth int) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	if v.Kind() == reflect.Interface {
+		return n.value(v.Elem(), depth)
+	}
+	if v.Type() == numberType {
+		if s := v.String(); json.Valid([]byte(s)) {
+			return json.RawMessage(s), nil
+		}
+		return nil, fmt.Errorf("json: invalid number literal %q", v.String())
+	}
+
+	if out, ok, err := n.marshal(v); ok {
+		return out, err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Float32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Pointer:
+		if !n.enter(v) {
+			return nil, nil
+		}
+		defer n.leave(v)
+		return n.value(v.Elem(), depth)
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if n.maxDepth > 0 && depth >= n.maxDepth {
+			return nil, nil
+		}
+		return n.container(v, depth+1)
+	default:
+		return nil, fmt.Errorf("json: unsupported type: %s", v.Type())
+	}
+}
+
+// container 转换map、切片、数组和结构体
+func (n *normalizer) container(v reflect.Value, depth int) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Map:
+		if !n.enter(v) {
+			return nil, nil
+		}
+		defer n.leave(v)
+
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			if out[key], err = n.value(iter.Value(), depth); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+
+	case reflect.Slice:
+		// []byte与encoding/json一样编码为Base64
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		if !n.enter(v) {
+			return nil, nil
+		}
+		defer n.leave(v)
+		return n.array(v, depth)
+
+	case reflect.Array:
+		return n.array(v, depth)
+
+	default:
+		out := make(map[string]interface{})
+		for _, f := range cachedFields(v.Type()) {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				// 经过nil的嵌入指针
+				continue
+			}
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			value, err := n.value(fv, depth)
+			if err != nil {
+				return nil, err
+			}
+			if f.quoted {
+				value = quote(value)
+			}
+			out[f.name] = value
+		}
+		return out, nil
+	}
+}
+
+// array 转换切片和数组的元素
+func (n *normalizer) array(v reflect.Value, depth int) (interface{}, error) {
+	out := make([]interface{}, v.Len())
+	for i := range out {
+		var err error
+		if out[i], err = n.value(v.Index(i), depth); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// marshal 使用值自身的编码方法，没有可用方法时ok为false
+// 方法发生panic时作为编码错误返回
+func (n *normalizer) marshal(v reflect.Value) (out interface{}, ok bool, err error) {
+	// 通过未导出的嵌入字段访问到的值不能调用方法
+	t := v.Type()
+	if !v.CanInterface() {
+		return nil, false, nil
+	}
+	if !t.Implements(jsonMarshalerType) && !t.Implements(textMarshalerType) && !t.Implements(errorType) &&
+		!(t.Implements(stringerType) && stringable(v)) {
+		return nil, false, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			out, ok, err = nil, true, fmt.Errorf("json: panic calling method of %s: %v", t, r)
+		}
+	}()
+
+	switch m := v.Interface().(type) {
+	case json.Marshaler:
+		data, err := m.MarshalJSON()
+		if err != nil {
+			return nil, true, fmt.Errorf("json: error calling MarshalJSON for type %s: %w", t, err)
+		}
+		if !json.Valid(data) {
+			return nil, true, fmt.Errorf("json: invalid output from MarshalJSON for type %s", t)
+		}
+		return json.RawMessage(data), true, nil
+	case encoding.TextMarshaler:
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, true, fmt.Errorf("json: error calling MarshalText for type %s: %w", t, err)
+		}
+		return string(text), true, nil
+	case error:
+		return m.Error(), true, nil
+	default:
+		return v.Interface().(fmt.Stringer).String(), true, nil
+	}
+}
+
+// stringable 判断是否使用fmt.Stringer编码
+// 数字、字符串等基本类型保持原值，避免带String方法的枚举类型改变字段的映射类型
+func stringable(v reflect.Value) bool {
+	k := v.Kind()
+	if k == reflect.Pointer {
+		k = v.Type().Elem().Kind()
+	}
+	return k == reflect.Struct
+}
+
+// enter 记录进入的引用，已在当前路径上（循环引用）时返回false
+func (n *normalizer) enter(v reflect.Value) bool {
+	if n.visited == nil {
+		n.visited = make(map[visit]struct{})
+	}
+	key := visitOf(v)
+	if _, ok := n.visited[key]; ok {
+		return false
+	}
+	n.visited[key] = struct{}{}
+	return true
+}
+
+// leave 离开引用
+func (n *normalizer) leave(v reflect.Value) {
+	delete(n.visited, visitOf(v))
+}
+
+// visitOf 返回指针、map或切片的标识
+func visitOf(v reflect.Value) visit {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	return key
+}
+
+// mapKey 按encoding/json的规则转换map的键
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if k.CanInterface() {
+		if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+			if k.Kind() == reflect.Pointer && k.IsNil() {
+				return "", nil
+			}
+			text, err := tm.MarshalText()
+			return string(text), err
+		}
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("json: unsupported map key type: %s", k.Type())
+}
+
+// quote 处理json标签的string选项，将数字和布尔值编码为字符串
+func quote(value interface{}) interface{} {
+	switch value.(type) {
+	case bool, int64, uint64, float32, float64:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return value
+	}
+}
+
+// isEmptyValue 判断omitempty时是否省略
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+// structField 结构体中参与编码的字段
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	quoted    bool
+}
+
+// structFieldCache 结构体类型 -> []structField
+var structFieldCache sync.Map
+
+// cachedFields 返回结构体参与编码的字段
+func cachedFields(t reflect.Type) []structField {
+	if fields, ok := structFieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldCache.LoadOrStore(t, typeFields(t))
+	return fields.([]structField)
+}
+
+// typeFields 按json标签收集结构体字段
+// 未命名的嵌入结构体字段提升到外层，层级浅的字段优先，同一层级同名的字段都被忽略
+func typeFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []structField
+	names := map[string]bool{}
+	seen := map[reflect.Type]bool{}
+
+	for current := []embedded{{typ: t}}; len(current) > 0; {
+		var next []embedded
+		var level []structField
+		count := map[string]int{}
+
+		for _, e := range current {
+			if seen[e.typ] {
+				continue
+			}
+			seen[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(slices.Clip(e.index), i)
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+
+				options := strings.Split(opts, ",")
+				level = append(level, structField{
+					name:      name,
+					index:     index,
+					omitEmpty: slices.Contains(options, "omitempty"),
+					quoted:    slices.Contains(options, "string"),
+				})
+				count[name]++
+			}
+		}
+
+		for _, f := range level {
+			if count[f.name] == 1 && !names[f.name] {
+				fields = append(fields, f)
+			}
+		}
+		for name := range count {
+			names[name] = true
+		}
+		current = next
+	}
+	return fields
+}
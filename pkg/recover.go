@@ -0,0 +1,69 @@
+package elk_logger
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// RecoverAndLog 捕获panic并以Fatal级别同步发送到ES
+// 必须直接通过defer调用：defer client.RecoverAndLog(fields)
+// 配置RepanicAfterRecover时，记录后重新抛出panic
+func (c *Client) RecoverAndLog(fields Fields) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	c.logPanic(r, fields)
+
+	if c.cfg().RepanicAfterRecover {
+		panic(r)
+	}
+}
+
+// Go 启动协程执行fn，协程内的panic会被记录到ES
+func (c *Client) Go(fn func()) {
+	go func() {
+		defer c.RecoverAndLog(nil)
+		fn()
+	}()
+}
+
+// logPanic 记录panic值、完整堆栈和panic发生位置
+func (c *Client) logPanic(r interface{}, fields Fields) {
+	entry, err := c.newEntry(LevelFatal, fmt.Sprintf("panic: %v", r), []Fields{fields}, []Field{String("panic", fmt.Sprint(r))})
+	if err != nil {
+		return
+	}
+	entry.Stack = string(debug.Stack())
+	entry.Caller = panicCaller()
+
+	entries := []*LogEntry{entry}
+	_ = c.deliver(entries, c.cfg().FatalFlushTimeout)
+	c.release(entries)
+}
+
+// panicCaller 返回触发panic的调用位置
+func panicCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	// 跳过runtime.gopanic之前的帧，其后第一个非runtime帧即为panic位置
+	afterPanic := false
+	for {
+		frame, more := frames.Next()
+		if afterPanic && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if frame.Function == "runtime.gopanic" {
+			afterPanic = true
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package elk_logger
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrRestartRequired 配置变化无法热更新，需要重启客户端
+var ErrRestartRequired = errors.New("config change requires restart")
+
+// UpdateConfig 热更新客户端配置
+// 级别、采样、批量大小与超时、刷新间隔、索引模式、重试等配置立即生效；
+// ES地址、认证或压缩变化时重建发送器，队列中的日志不受影响；
+// 队列容量、协程数、自适应批量开关、熔断配置、备用集群列表和多集群模式需要重启客户端，
+// 变化时返回错误且不应用任何修改
+func (c *Client) UpdateConfig(newConfig *Config) error {
+	if newConfig == nil {
+		return ErrInvalidConfig{msg: "config cannot be nil"}
+	}
+	newConfig = newConfig.withDefaults()
+	if err := newConfig.Validate(); err != nil {
+		return err
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return fmt.Errorf("client is closed")
+	}
+
+	old := c.cfg()
+	if unsafe := unsafeChanges(old, newConfig); len(unsafe) > 0 {
+		return fmt.Errorf("%w: %s", ErrRestartRequired, strings.Join(unsafe, ", "))
+	}
+
+	// 先为每个集群准备新发送器，任一失败时保持原配置
+	senders := make([]*Sender, len(c.destinations))
+	for i, d := range c.destinations {
+		sender := d.sender.Load()
+		prev, next := d.configFor(old), d.configFor(newConfig)
+		if needsNewSender(prev, next) {
+			rebuilt, err := newSender(next, c.metrics)
+			if err != nil {
+				return fmt.Errorf("failed to rebuild sender for cluster %s: %w", d.name, err)
+			}
+			rebuilt.inflight = sender.inflight
+			sender = rebuilt
+		} else {
+			sender = sender.withConfig(next)
+		}
+		senders[i] = sender
+	}
+
+	c.config.Store(newConfig)
+	for i, d := range c.destinations {
+		oldSender := d.sender.Swap(senders[i])
+		if oldSender.client != senders[i].client {
+			_ = oldSender.Close()
+		}
+	}
+
+	// 批量参数
+	c.batch.SetMaxBytes(newConfig.MaxBatchBytes)
+	if c.adaptive != nil {
+		c.adaptive.reset(newConfig)
+	} else {
+		c.batch.SetMaxSize(newConfig.BatchSize)
+		c.batch.SetTimeout(newConfig.BatchTimeout)
+		c.metrics.SetBatchParams(newConfig.BatchSize, newConfig.BatchTimeout)
+	}
+
+	// 通知定时刷新协程，丢弃尚未处理的旧值
+	if newConfig.FlushInterval != old.FlushInterval {
+		select {
+		case <-c.flushInterval:
+		default:
+		}
+		c.flushInterval <- newConfig.FlushInterval
+	}
+
+	return nil
+}
+
+// WatchConfig 定期检查配置文件，文件修改后重新加载并热更新
+// onReload在每次重新加载后调用，err为nil表示更新成功，可以为nil
+func (c *Client) WatchConfig(path string, interval time.Duration, onReload func(err error)) error {
+	if interval <= 0 {
+		return ErrInvalidConfig{msg: "watch interval must be greater than 0"}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return fmt.Errorf("client is closed")
+	}
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+
+		lastMod := info.ModTime()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || !info.ModTime().After(lastMod) {
+					continue
+				}
+				lastMod = info.ModTime()
+
+				err = c.reloadFile(path)
+				if onReload != nil {
+					onReload(err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadFile 重新加载配置文件并热更新
+// 文件中无法表示的配置（回调、自定义传输层、调试输出等）沿用当前值
+func (c *Client) reloadFile(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	keepCodeOnly(config, c.cfg())
+	return c.UpdateConfig(config)
+}
+
+// keepCodeOnly 将json标签为"-"、只能在代码中设置的配置从current复制到config
+func keepCodeOnly(config, current *Config) {
+	dst, src := reflect.ValueOf(config).Elem(), reflect.ValueOf(current).Elem()
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") == "-" {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// unsafeChanges 返回不能热更新的配置项
+func unsafeChanges(prev, next *Config) []string {
+	var changed []string
+	check := func(name string, differ bool) {
+		if differ {
+			changed = append(changed, name)
+		}
+	}
+
+	check("queue_size", prev.QueueSize != next.QueueSize)
+	check("high_priority_queue_size", prev.HighPriorityQueueSize != next.HighPriorityQueueSize)
+	check("low_priority_queue_size", prev.LowPriorityQueueSize != next.LowPriorityQueueSize)
+	check("worker_count", prev.WorkerCount != next.WorkerCount)
+	check("sender_count", prev.SenderCount != next.SenderCount)
+	check("max_inflight_requests", prev.MaxInflightRequests != next.MaxInflightRequests)
+	check("adaptive_batch", prev.AdaptiveBatch != next.AdaptiveBatch)
+	check("circuit_breaker_threshold", prev.CircuitBreakerThreshold != next.CircuitBreakerThreshold)
+	check("circuit_breaker_timeout", prev.CircuitBreakerTimeout != next.CircuitBreakerTimeout)
+	check("clusters", !reflect.DeepEqual(prev.Clusters, next.Clusters))
+	check("cluster_mode", prev.ClusterMode != next.ClusterMode)
+
+	return changed
+}
+
+// needsNewSender 判断是否需要重建ES连接
+func needsNewSender(prev, next *Config) bool {
+	return !slices.Equal(prev.ESAddresses, next.ESAddresses) ||
+		prev.ESUsername != next.ESUsername ||
+		prev.ESPassword != next.ESPassword ||
+		prev.ESPasswordFile != next.ESPasswordFile ||
+		prev.ESAPIKey != next.ESAPIKey ||
+		prev.ESAPIKeyFile != next.ESAPIKeyFile ||
+		prev.ESServiceToken != next.ESServiceToken ||
+		prev.ESServiceTokenFile != next.ESServiceTokenFile ||
+		prev.ESCloudID != next.ESCloudID ||
+		prev.ESCACert != next.ESCACert ||
+		prev.ESClientCert != next.ESClientCert ||
+		prev.ESClientKey != next.ESClientKey ||
+		prev.ESInsecureSkipVerify != next.ESInsecureSkipVerify ||
+		prev.EnableSniffing != next.EnableSniffing ||
+		prev.SniffInterval != next.SniffInterval ||
+		prev.NodeResurrectTimeout != next.NodeResurrectTimeout ||
+		prev.ConnectionTimeout != next.ConnectionTimeout ||
+		prev.ResponseHeaderTimeout != next.ResponseHeaderTimeout ||
+		prev.MaxIdleConns != next.MaxIdleConns ||
+		prev.MaxIdleConnsPerHost != next.MaxIdleConnsPerHost ||
+		!sameValue(prev.Transport, next.Transport) ||
+		prev.EnableDebug != next.EnableDebug ||
+		!sameValue(prev.DebugWriter, next.DebugWriter) ||
+		prev.EnableCompression != next.EnableCompression
+}
+
+// sameValue 比较两个接口值是否相同，不可比较的类型（如函数适配器）视为不同
+func sameValue(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
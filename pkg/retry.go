@@ -0,0 +1,127 @@
+package elk_logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// transportError 批量请求未得到响应（网络错误或单次请求超时）
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// retryableStatus 判断HTTP状态码是否可重试
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryable 判断发送错误是否值得重试
+// 总超时到期或被取消后不再重试
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var tErr *transportError
+	if errors.As(err, &tErr) {
+		return true
+	}
+
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		return retryableStatus(bulkErr.StatusCode)
+	}
+
+	return false
+}
+
+// retryBackoff 返回第attempt次重试前的等待时间
+// 服务端返回Retry-After时按其要求等待，否则使用带全抖动的指数退避
+func (s *Sender) retryBackoff(attempt int, err error) time.Duration {
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) && bulkErr.RetryAfter > 0 {
+		return bulkErr.RetryAfter
+	}
+	return fullJitter(s.config.RetryInterval, s.config.MaxRetryBackoff, attempt)
+}
+
+// fullJitter 在[0, min(max, base*2^(attempt-1))]内随机取值
+func fullJitter(base, max time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	ceiling := base
+	for i := 1; i < attempt && ceiling < max; i++ {
+		ceiling *= 2
+	}
+	if max > 0 && ceiling > max {
+		ceiling = max
+	}
+
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// parseRetryAfter 解析Retry-After响应头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// split 按批量响应拆分请求体
+// 返回由可重试的失败条目组成的新请求体及这些条目的失败信息，以及不可重试的失败条目
+// 响应条目数与请求不一致时无法对应到条目，ok为false
+func (b bulkBody) split(items []BulkResponseItem) (next bulkBody, retry, permanent []FailedItem, ok bool) {
+	lines := bytes.Split(bytes.TrimSuffix(b.data, []byte("\n")), []byte("\n"))
+	if len(items) != len(b.entries) || len(lines) != 2*len(items) {
+		return bulkBody{}, nil, nil, false
+	}
+
+	var buf bytes.Buffer
+	for i, item := range items {
+		if !item.failed() {
+			continue
+		}
+		failure := item.failure(b.entries[i])
+		if !failure.retryable() {
+			permanent = append(permanent, failure)
+			continue
+		}
+		retry = append(retry, failure)
+		next.entries = append(next.entries, b.entries[i])
+		buf.Write(lines[2*i])
+		buf.WriteByte('\n')
+		buf.Write(lines[2*i+1])
+		buf.WriteByte('\n')
+	}
+	next.data = buf.Bytes()
+	return next, retry, permanent, true
+}
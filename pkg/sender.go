@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
@@ -16,30 +20,63 @@ type Sender struct {
 	client       *elasticsearch.Client
 	indexPattern string
 	config       *Config
+	metrics      *Metrics
+	inflight     chan struct{} // 并发批量请求信号量，nil表示不限制
+	actions      *actionCache  // 批量操作行缓存
 }
 
 // NewSender 创建新的发送器
 func NewSender(config *Config) (*Sender, error) {
+	return newSender(config, NewMetrics())
+}
+
+// newSender 创建使用指定指标的发送器
+func newSender(config *Config, metrics *Metrics) (*Sender, error) {
+	creds, err := loadCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 节点健康感知的连接池，嗅探到新节点时更新
+	pool := newNodePool(config.NodeResurrectTimeout, metrics)
+	transport = &nodeTracker{next: transport, pool: pool, metrics: metrics}
+
 	esConfig := elasticsearch.Config{
-		Addresses: config.ESAddresses,
-		Username:  config.ESUsername,
-		Password:  config.ESPassword,
-
-		// 连接池配置
-		MaxRetries: config.RetryCount,
-		RetryBackoff: func(i int) time.Duration {
-			// 指数退避
-			d := time.Duration(i) * config.RetryInterval
-			if d > config.MaxRetryBackoff {
-				return config.MaxRetryBackoff
-			}
-			return d
-		},
+		Addresses:    config.ESAddresses,
+		Username:     creds.username,
+		Password:     creds.password,
+		APIKey:       creds.apiKey,
+		ServiceToken: creds.serviceToken,
+		CloudID:      config.ESCloudID,
+
+		// 重试由SendWithRetry统一处理，关闭传输层重试避免重复计数
+		DisableRetry: true,
 
 		// 启用压缩
 		EnableMetrics:       true,
-		EnableDebugLogger:   false,
 		CompressRequestBody: config.EnableCompression,
+
+		Transport: transport,
+		Logger:    newDebugLogger(config),
+
+		// 节点发现
+		DiscoverNodesOnStart: config.EnableSniffing,
+		ConnectionPoolFunc: func(conns []*elastictransport.Connection, _ elastictransport.Selector) elastictransport.ConnectionPool {
+			_ = pool.Update(conns)
+			return pool
+		},
+	}
+	if config.EnableSniffing {
+		esConfig.DiscoverNodesInterval = config.SniffInterval
+	}
+
+	// Cloud ID与地址列表不能同时设置
+	if config.ESCloudID != "" {
+		esConfig.Addresses = nil
 	}
 
 	client, err := elasticsearch.NewClient(esConfig)
@@ -61,53 +98,339 @@ func NewSender(config *Config) (*Sender, error) {
 		return nil, fmt.Errorf("elasticsearch ping returned error: %s", res.Status())
 	}
 
-	return &Sender{
+	sender := &Sender{
 		client:       client,
 		indexPattern: config.IndexPattern,
 		config:       config,
-	}, nil
+		metrics:      metrics,
+		actions:      &actionCache{},
+	}
+	if config.MaxInflightRequests > 0 {
+		sender.inflight = make(chan struct{}, config.MaxInflightRequests)
+	}
+
+	return sender, nil
+}
+
+// withConfig 返回使用新配置的发送器，复用ES连接、并发信号量和指标
+func (s *Sender) withConfig(config *Config) *Sender {
+	clone := *s
+	clone.indexPattern = config.IndexPattern
+	clone.config = config
+	clone.actions = &actionCache{}
+	return &clone
 }
 
 // Send 发送日志批次到ES
+// 超过MaxBatchBytes的批次会被拆分为多个批量请求依次发送
 func (s *Sender) Send(ctx context.Context, entries []*LogEntry) error {
+	bodies, err := s.buildBulkBodies(entries)
+	if err != nil {
+		return err
+	}
+
+	for _, body := range bodies {
+		if err := s.sendBulk(ctx, body.data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendWithRetry 带重试的发送
+// 拆分后的每个批量请求独立重试，已成功的部分不会重复发送
+// 有条目写入失败时返回*SendError，其中列出失败的条目，其余条目已写入成功
+func (s *Sender) SendWithRetry(ctx context.Context, entries []*LogEntry) error {
+	bodies, err := s.buildBulkBodies(entries)
+	if err != nil {
+		return err
+	}
+
+	var failed []FailedItem
+	for i, body := range bodies {
+		items, err := s.sendBulkWithRetry(ctx, body)
+		failed = append(failed, items...)
+		if err != nil {
+			// 请求级别失败后不再发送剩余的请求
+			for _, rest := range bodies[i+1:] {
+				failed = append(failed, rest.fail(err)...)
+			}
+			return &SendError{Failed: failed, Err: err}
+		}
+	}
+
+	if len(failed) > 0 {
+		return &SendError{Failed: failed}
+	}
+	return nil
+}
+
+// sendBulkWithRetry 带重试地发送单个批量请求，返回写入失败的条目
+// 只重试网络错误、超时和429/502/503/504；部分条目失败时不可重试的条目直接记为失败，只重发可重试的条目
+// 请求级别的失败返回错误，此时请求中尚未写入的条目都在失败列表中
+// ctx的截止时间即为包含全部重试的总超时
+func (s *Sender) sendBulkWithRetry(ctx context.Context, body bulkBody) ([]FailedItem, error) {
+	var failed []FailedItem
+	attempts := 0
+	for {
+		err := s.sendBulk(ctx, body.data)
+		attempts++
+		if err == nil {
+			return failed, nil
+		}
+
+		// 条目级别的失败，放弃重试时剩余的条目也按条目级别的失败返回
+		var pending []FailedItem
+		var bulkErr *BulkError
+		if errors.As(err, &bulkErr) && bulkErr.items != nil {
+			if next, retry, permanent, ok := body.split(bulkErr.items); ok {
+				failed = append(failed, permanent...)
+				if len(retry) == 0 {
+					return failed, nil
+				}
+				body, pending = next, retry
+			}
+		}
+		giveUp := func(err error) ([]FailedItem, error) {
+			if pending != nil {
+				return append(failed, pending...), nil
+			}
+			return append(failed, body.fail(err)...), err
+		}
+
+		if pending == nil && !isRetryable(ctx, err) || ctx.Err() != nil {
+			return giveUp(err)
+		}
+		if attempts > s.config.RetryCount {
+			return giveUp(fmt.Errorf("failed after %d attempts: %w", attempts, err))
+		}
+
+		// 剩余时间不够等待时不再重试
+		backoff := s.retryBackoff(attempts, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return giveUp(fmt.Errorf("send deadline exceeded after %d attempts: %w", attempts, err))
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return giveUp(fmt.Errorf("send deadline exceeded after %d attempts: %w", attempts, err))
+		case <-timer.C:
+		}
+	}
+}
+
+// bulkBody 批量请求体及其中各文档对应的日志条目
+type bulkBody struct {
+	data    []byte
+	entries []*LogEntry // 按文档在请求体中的顺序，不包含被拒绝的条目
+}
+
+// fail 请求级别失败时，将请求中的所有条目记为失败
+func (b bulkBody) fail(err error) []FailedItem {
+	status := 0
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) {
+		status = bulkErr.StatusCode
+	}
+
+	failed := make([]FailedItem, len(b.entries))
+	for i, entry := range b.entries {
+		failed[i] = FailedItem{Entry: entry, Status: status, Reason: err.Error()}
+	}
+	return failed
+}
+
+// buildBulkBodies 编码日志条目并按MaxBatchBytes拆分为多个批量请求体
+// 文档直接写入池化缓冲区，每个请求体只复制一次
+func (s *Sender) buildBulkBodies(entries []*LogEntry) ([]bulkBody, error) {
 	if len(entries) == 0 {
-		return nil
+		return nil, nil
 	}
 
-	// 构建批量请求
-	var buf bytes.Buffer
+	maxBytes := s.config.MaxBatchBytes
+	encoder := newDocumentEncoder(s.config)
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	var bodies []bulkBody
+	var included []*LogEntry
+	b := *buf
 	for _, entry := range entries {
 		// 索引元数据
-		meta := map[string]interface{}{
-			"index": map[string]interface{}{
-				"_index": s.getIndexName(entry.Timestamp),
-			},
-		}
-		metaJSON, _ := json.Marshal(meta)
-		buf.Write(metaJSON)
-		buf.WriteByte('\n')
+		start := len(b)
+		b = append(b, s.actions.line(s, entry.Timestamp)...)
 
 		// 文档数据
-		docJSON, err := entry.ToJSON()
+		var ok bool
+		var err error
+		b, ok, err = s.appendDocument(b, encoder, entry)
 		if err != nil {
-			return fmt.Errorf("failed to marshal log entry: %w", err)
+			*buf = b
+			return nil, err
+		}
+		if !ok {
+			b = b[:start]
+			continue
+		}
+		b = append(b, '\n')
+
+		// 加入当前文档后超限时，先切出之前的部分作为一个请求
+		if maxBytes > 0 && start > 0 && len(b) > maxBytes {
+			bodies = append(bodies, bulkBody{data: bytes.Clone(b[:start]), entries: included})
+			b = b[:copy(b, b[start:])]
+			included = nil
+		}
+		included = append(included, entry)
+	}
+
+	if len(b) > 0 {
+		bodies = append(bodies, bulkBody{data: bytes.Clone(b), entries: included})
+	}
+	*buf = b
+	return bodies, nil
+}
+
+// appendDocument 将单条文档追加到b
+// 客户端已处理的条目直接使用编码好的文档，其余条目在这里处理大小限制后编码
+// 文档因超限或自定义字段冲突被拒绝时返回false，b保持原长度
+func (s *Sender) appendDocument(b []byte, encoder documentEncoder, entry *LogEntry) ([]byte, bool, error) {
+	if entry.prepared {
+		return append(b, entry.document...), len(entry.document) > 0, nil
+	}
+	return appendLimitedDocument(b, s.config, encoder, s.metrics, entry)
+}
+
+// appendLimitedDocument 编码单条文档追加到b，并处理消息、字段和单文档大小限制
+// 文档因超限或自定义字段冲突被拒绝时返回false，b保持原长度
+// 截断作用于条目副本，每次调用对截断、拒绝和编码错误各计数一次
+func appendLimitedDocument(b []byte, config *Config, encoder documentEncoder, metrics *Metrics, entry *LogEntry) ([]byte, bool, error) {
+	limited := exceedsLimits(config, entry)
+	if limited {
+		entry = limitEntry(config, entry)
+	}
+
+	start := len(b)
+	b, err := encoder.appendDocument(b, entry)
+	if errors.Is(err, ErrFieldCollision) {
+		metrics.IncRejected()
+		return b[:start], false, nil
+	}
+	if err != nil {
+		// 自定义字段无法编码时只发送内置字段和错误信息，不影响同批次的其他日志
+		metrics.IncEncodingError()
+		b, err = encoder.appendDocument(b[:start], encodingErrorEntry(entry, err))
+		if err != nil {
+			return b[:start], false, fmt.Errorf("failed to marshal log entry: %w", err)
+		}
+	}
+
+	maxBytes := config.MaxDocumentBytes
+	if maxBytes <= 0 || len(b)-start <= maxBytes {
+		if limited {
+			metrics.IncTruncated()
+		}
+		return b, true, nil
+	}
+
+	if config.TruncateOversizeDoc {
+		if truncated, ok := truncateDocument(entry, b[start:], maxBytes, encoder.encode); ok {
+			metrics.IncTruncated()
+			return append(b[:start], truncated...), true, nil
 		}
-		buf.Write(docJSON)
-		buf.WriteByte('\n')
 	}
 
-	// 发送批量请求
+	metrics.IncRejected()
+	return b[:start], false, nil
+}
+
+// encodingErrorEntry 返回去掉自定义字段并记录编码错误的条目副本
+func encodingErrorEntry(entry *LogEntry, err error) *LogEntry {
+	clone := *entry
+	clone.Fields = nil
+	clone.TypedFields = nil
+	clone.EncodingError = err.Error()
+	return &clone
+}
+
+// truncateDocument 依次截断堆栈和消息，使文档编码后不超过maxBytes，文档中带有截断标记
+// 自定义字段本身超限时无法截断，返回false
+func truncateDocument(entry *LogEntry, docJSON []byte, maxBytes int, encode func(*LogEntry) ([]byte, error)) ([]byte, bool) {
+	clone := entry.Clone()
+	clone.markTruncated()
+
+	// JSON转义会放大字节数，截断后需重新编码校验
+	for i := 0; i < 3 && len(docJSON) > maxBytes; i++ {
+		overflow := len(docJSON) - maxBytes
+
+		if n := len(clone.Stack); n > 0 {
+			cut := overflow
+			if cut > n {
+				cut = n
+			}
+			clone.Stack = truncateString(clone.Stack, n-cut)
+			overflow -= cut
+		}
+		if n := len(clone.Message); overflow > 0 && n > 0 {
+			cut := overflow
+			if cut > n {
+				cut = n
+			}
+			clone.Message = truncateString(clone.Message, n-cut)
+			overflow -= cut
+		}
+		if overflow > 0 {
+			return nil, false
+		}
+
+		var err error
+		docJSON, err = encode(clone)
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	return docJSON, len(docJSON) <= maxBytes
+}
+
+// sendBulk 发送单个批量请求
+func (s *Sender) sendBulk(ctx context.Context, body []byte) error {
+	// 限制同时进行的批量请求数
+	if s.inflight != nil {
+		select {
+		case s.inflight <- struct{}{}:
+			defer func() { <-s.inflight }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// 单次请求超时，不包含等待信号量的时间
+	if s.config.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.RequestTimeout)
+		defer cancel()
+	}
+
 	res, err := s.client.Bulk(
-		bytes.NewReader(buf.Bytes()),
+		bytes.NewReader(body),
 		s.client.Bulk.WithContext(ctx),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to send bulk request: %w", err)
+		return &transportError{err: fmt.Errorf("failed to send bulk request: %w", err)}
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("bulk request returned error: %s", res.Status())
+		return &BulkError{
+			StatusCode: res.StatusCode,
+			Message:    "bulk request returned error: " + res.Status(),
+			Rejected:   res.StatusCode == http.StatusTooManyRequests,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
 	}
 
 	// 解析响应检查是否有错误
@@ -117,42 +440,16 @@ func (s *Sender) Send(ctx context.Context, entries []*LogEntry) error {
 	}
 
 	if bulkRes.Errors {
-		// 有部分失败，但不返回错误，让上层决定如何处理
-		// 可以在这里记录详细的错误信息
-		return fmt.Errorf("bulk request has errors")
-	}
-
-	return nil
-}
-
-// SendWithRetry 带重试的发送
-func (s *Sender) SendWithRetry(ctx context.Context, entries []*LogEntry) error {
-	var lastErr error
-
-	for i := 0; i <= s.config.RetryCount; i++ {
-		if i > 0 {
-			// 重试前等待
-			backoff := time.Duration(i) * s.config.RetryInterval
-			if backoff > s.config.MaxRetryBackoff {
-				backoff = s.config.MaxRetryBackoff
-			}
-
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
-
-		err := s.Send(ctx, entries)
-		if err == nil {
-			return nil
+		// 部分条目失败，由调用方按条目结果决定重发或记为失败
+		return &BulkError{
+			StatusCode: res.StatusCode,
+			Message:    "bulk request has errors",
+			Rejected:   bulkRes.hasRejected(),
+			items:      bulkRes.results(),
 		}
-
-		lastErr = err
 	}
 
-	return fmt.Errorf("failed after %d retries: %w", s.config.RetryCount, lastErr)
+	return nil
 }
 
 // getIndexName 根据时间戳生成索引名
@@ -165,6 +462,34 @@ func (s *Sender) getIndexName(timestamp time.Time) string {
 	return indexName
 }
 
+// actionCache 缓存最近一天的批量操作行，索引名只与日期有关
+type actionCache struct {
+	last atomic.Pointer[actionLine]
+}
+
+// actionLine 某一天的批量操作行，如 {"index":{"_index":"logs-2024.01.02"}}
+type actionLine struct {
+	year  int
+	month time.Month
+	day   int
+	line  []byte
+}
+
+// line 返回时间戳对应的批量操作行（含换行符）
+func (c *actionCache) line(s *Sender, timestamp time.Time) []byte {
+	year, month, day := timestamp.Date()
+	if a := c.last.Load(); a != nil && a.year == year && a.month == month && a.day == day {
+		return a.line
+	}
+
+	a := &actionLine{year: year, month: month, day: day}
+	a.line = append(a.line, `{"index":{"_index":`...)
+	a.line = appendString(a.line, s.getIndexName(timestamp))
+	a.line = append(a.line, "}}\n"...)
+	c.last.Store(a)
+	return a.line
+}
+
 // Close 关闭发送器
 func (s *Sender) Close() error {
 	// go-elasticsearch客户端不需要显式关闭
@@ -177,6 +502,32 @@ type BulkResponse struct {
 	Items  []map[string]BulkResponseItem `json:"items"`
 }
 
+// results 按请求中的文档顺序返回各条目的结果
+func (r *BulkResponse) results() []BulkResponseItem {
+	items := make([]BulkResponseItem, 0, len(r.Items))
+	for _, item := range r.Items {
+		for _, result := range item {
+			items = append(items, result)
+		}
+	}
+	return items
+}
+
+// hasRejected 判断是否有条目被集群拒绝执行
+func (r *BulkResponse) hasRejected() bool {
+	for _, item := range r.Items {
+		for _, result := range item {
+			if result.Status == http.StatusTooManyRequests {
+				return true
+			}
+			if result.Error != nil && result.Error.Type == "es_rejected_execution_exception" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // BulkResponseItem 批量响应项
 type BulkResponseItem struct {
 	Index  string `json:"_index"`
@@ -187,3 +538,116 @@ type BulkResponseItem struct {
 		Reason string `json:"reason"`
 	} `json:"error,omitempty"`
 }
+
+// failed 判断条目是否写入失败
+func (item BulkResponseItem) failed() bool {
+	return item.Error != nil || item.Status >= http.StatusMultipleChoices
+}
+
+// failure 返回条目的失败信息
+func (item BulkResponseItem) failure(entry *LogEntry) FailedItem {
+	f := FailedItem{Entry: entry, Status: item.Status}
+	if item.Error != nil {
+		f.Type = item.Error.Type
+		f.Reason = item.Error.Reason
+	}
+	return f
+}
+
+// BulkError 批量请求错误
+type BulkError struct {
+	StatusCode int           // HTTP状态码
+	Message    string        // 错误描述
+	Rejected   bool          // 是否被集群拒绝执行（429 / es_rejected_execution_exception）
+	RetryAfter time.Duration // 服务端通过Retry-After要求的等待时间
+
+	items []BulkResponseItem // 部分条目失败时各条目的结果，按请求中的文档顺序
+}
+
+func (e *BulkError) Error() string {
+	return e.Message
+}
+
+// FailedItem 写入失败的日志条目
+type FailedItem struct {
+	Entry  *LogEntry
+	Status int    // 条目的状态码，请求级别失败时为HTTP状态码，没有响应时为0
+	Type   string // ES错误类型，如mapper_parsing_exception
+	Reason string // 错误描述
+}
+
+// retryable 判断失败的条目是否可以重发
+func (f FailedItem) retryable() bool {
+	return retryableStatus(f.Status) || f.Type == "es_rejected_execution_exception"
+}
+
+// SendError 部分或全部日志条目写入失败，不在Failed中的条目已写入成功
+type SendError struct {
+	Failed []FailedItem
+	Err    error // 请求级别的错误（网络错误、超时、HTTP错误状态），只有条目级别的失败时为nil
+}
+
+func (e *SendError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	first := e.Failed[0]
+	return fmt.Sprintf("bulk request has errors: %d item(s) failed, first: [%d] %s: %s",
+		len(e.Failed), first.Status, first.Type, first.Reason)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Entries 返回写入失败的日志条目
+func (e *SendError) Entries() []*LogEntry {
+	entries := make([]*LogEntry, len(e.Failed))
+	for i, item := range e.Failed {
+		entries[i] = item.Entry
+	}
+	return entries
+}
+
+// clusterFailure 判断是否为集群故障：请求级别的失败或条目返回5xx
+// 映射错误等条目级别的4xx只与文档本身有关，不计入熔断
+func (e *SendError) clusterFailure() bool {
+	if e.Err != nil {
+		return true
+	}
+	for _, item := range e.Failed {
+		if item.Status >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	return false
+}
+
+// rejected 判断是否有条目被集群拒绝执行
+func (e *SendError) rejected() bool {
+	for _, item := range e.Failed {
+		if item.Status == http.StatusTooManyRequests || item.Type == "es_rejected_execution_exception" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRejected 判断错误是否为集群拒绝执行
+func IsRejected(err error) bool {
+	var bulkErr *BulkError
+	if errors.As(err, &bulkErr) && bulkErr.Rejected {
+		return true
+	}
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.rejected()
+}
+
+// failedEntries 返回发送失败的条目，err不是*SendError时所有条目都视为失败
+func failedEntries(entries []*LogEntry, err error) []*LogEntry {
+	var sendErr *SendError
+	if errors.As(err, &sendErr) {
+		return sendErr.Entries()
+	}
+	return entries
+}
@@ -0,0 +1,102 @@
+package elk_logger
+
+import "sync"
+
+// senderPool 发送协程池
+// 工作协程只负责组装批次，满批次交给固定数量的发送协程，使日志入队不受网络IO阻塞
+type senderPool struct {
+	pending chan []*LogEntry
+	send    func(entries []*LogEntry)
+	metrics *Metrics
+	wg      sync.WaitGroup
+
+	// closeMu保护pending通道的关闭，Submit持读锁发送，Close持写锁关闭
+	closeMu sync.RWMutex
+	closed  bool
+
+	// 已提交但未发送完成的批次数
+	mu    sync.Mutex
+	cond  *sync.Cond
+	count int
+}
+
+// newSenderPool 创建发送协程池并启动size个发送协程
+func newSenderPool(size int, send func(entries []*LogEntry), metrics *Metrics) *senderPool {
+	p := &senderPool{
+		pending: make(chan []*LogEntry, size),
+		send:    send,
+		metrics: metrics,
+	}
+	p.cond = sync.NewCond(&p.mu)
+
+	for i := 0; i < size; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+
+	return p
+}
+
+// Submit 提交一个批次，所有发送协程繁忙且等待队列已满时阻塞
+// 协程池关闭后在调用方协程中同步发送
+func (p *senderPool) Submit(entries []*LogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+
+	if p.closed {
+		p.send(entries)
+		return
+	}
+
+	p.add(1)
+	p.pending <- entries
+}
+
+// Wait 等待所有已提交的批次发送完成
+func (p *senderPool) Wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.count > 0 {
+		p.cond.Wait()
+	}
+}
+
+// Close 关闭协程池，等待剩余批次发送完成
+func (p *senderPool) Close() {
+	p.closeMu.Lock()
+	if p.closed {
+		p.closeMu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.pending)
+	p.closeMu.Unlock()
+
+	p.wg.Wait()
+}
+
+// run 发送协程
+func (p *senderPool) run() {
+	defer p.wg.Done()
+
+	for entries := range p.pending {
+		p.send(entries)
+		p.add(-1)
+	}
+}
+
+// add 调整待发送批次数
+func (p *senderPool) add(delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.count += delta
+	p.metrics.SetPendingBatches(p.count)
+	if p.count == 0 {
+		p.cond.Broadcast()
+	}
+}
@@ -0,0 +1,54 @@
+package elk_logger
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/elastic/elastic-transport-go/v8/elastictransport"
+)
+
+// newTransport 按配置创建ES传输层
+// 配置了自定义Transport时直接使用，否则基于http.DefaultTransport设置超时、连接池和TLS
+func newTransport(config *Config) (http.RoundTripper, error) {
+	if config.Transport != nil {
+		return config.Transport, nil
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   config.ConnectionTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// newDebugLogger 调试模式下返回记录请求和响应内容的日志器，否则返回nil
+func newDebugLogger(config *Config) elastictransport.Logger {
+	if !config.EnableDebug {
+		return nil
+	}
+
+	output := config.DebugWriter
+	if output == nil {
+		output = os.Stderr
+	}
+	return &elastictransport.TextLogger{
+		Output:             output,
+		EnableRequestBody:  true,
+		EnableResponseBody: true,
+	}
+}
@@ -0,0 +1,305 @@
+package elk_logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FieldType 自定义字段在ES映射中的类型
+type FieldType string
+
+const (
+	FieldTypeString  FieldType = "string"  // 字符串，包括time.Time
+	FieldTypeNumber  FieldType = "number"  // 整数和浮点数
+	FieldTypeBoolean FieldType = "boolean" // 布尔值
+	FieldTypeObject  FieldType = "object"  // map和编码为对象的结构体
+)
+
+// valid 判断类型是否合法
+func (t FieldType) valid() bool {
+	switch t {
+	case FieldTypeString, FieldTypeNumber, FieldTypeBoolean, FieldTypeObject:
+		return true
+	default:
+		return false
+	}
+}
+
+// suffix 重命名冲突字段时使用的后缀
+func (t FieldType) suffix() string {
+	switch t {
+	case FieldTypeString:
+		return "_str"
+	case FieldTypeNumber:
+		return "_num"
+	case FieldTypeBoolean:
+		return "_bool"
+	default:
+		return "_obj"
+	}
+}
+
+// TypeConflictPolicy 字段值与期望类型不一致时的处理方式
+type TypeConflictPolicy string
+
+const (
+	TypeConflictCoerce    TypeConflictPolicy = "coerce"    // 转换为期望类型，无法转换时按rename处理
+	TypeConflictRename    TypeConflictPolicy = "rename"    // 按实际类型重命名，如 user_id -> user_id_str
+	TypeConflictStringify TypeConflictPolicy = "stringify" // 转为字符串；期望类型不是字符串时写入 user_id_str
+)
+
+// valid 判断策略是否合法
+func (p TypeConflictPolicy) valid() bool {
+	switch p {
+	case TypeConflictCoerce, TypeConflictRename, TypeConflictStringify:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxInferredFields 推断类型时最多记录的字段数，避免动态字段名导致内存无限增长
+const maxInferredFields = 10000
+
+// typeGuard 字段类型保护，避免同名字段类型不一致导致ES映射冲突（mapper_parsing_exception）
+// 字段的期望类型来自Config.FieldTypes，未配置的字段在启用InferFieldTypes时以首次出现的类型为准
+type typeGuard struct {
+	inferred sync.Map // 字段名 -> 首次出现的FieldType
+	count    atomic.Int64
+	metrics  *Metrics
+}
+
+// newTypeGuard 创建字段类型保护
+func newTypeGuard(metrics *Metrics) *typeGuard {
+	return &typeGuard{metrics: metrics}
+}
+
+// apply 检查并修正条目中与期望类型冲突的字段
+// 条目的字段由客户端复制而来，直接在条目上修改
+func (g *typeGuard) apply(config *Config, entry *LogEntry) {
+	if entry.fieldCount() == 0 || (len(config.FieldTypes) == 0 && !config.InferFieldTypes) {
+		return
+	}
+
+	// 遍历map时先记录冲突，遍历结束后再修改
+	var conflicts []string
+	for key, value := range entry.Fields {
+		if g.conflicts(config, key, fieldTypeOf(value)) {
+			conflicts = append(conflicts, key)
+		}
+	}
+	for _, key := range conflicts {
+		value := entry.Fields[key]
+		delete(entry.Fields, key)
+		newKey, newValue := g.resolve(config, key, value, fieldTypeOf(value))
+		if newKey != key {
+			newKey = freeKey(entry, newKey)
+		}
+		entry.Fields[newKey] = newValue
+	}
+
+	for i, f := range entry.TypedFields {
+		actual := f.fieldType()
+		if g.conflicts(config, f.Key, actual) {
+			newKey, newValue := g.resolve(config, f.Key, f.Value(), actual)
+			if newKey != f.Key {
+				newKey = freeKey(entry, newKey)
+			}
+			entry.TypedFields[i] = Any(newKey, newValue)
+		}
+	}
+}
+
+// freeKey 返回条目中未被占用的键，避免重命名覆盖调用方的同名字段
+// key已存在时依次尝试key_2、key_3……
+func freeKey(entry *LogEntry, key string) string {
+	if !entry.hasField(key) {
+		return key
+	}
+	for i := 2; ; i++ {
+		candidate := key + "_" + strconv.Itoa(i)
+		if !entry.hasField(candidate) {
+			return candidate
+		}
+	}
+}
+
+// conflicts 判断字段值的类型是否与期望类型冲突
+func (g *typeGuard) conflicts(config *Config, key string, actual FieldType) bool {
+	if actual == "" {
+		return false
+	}
+	expected := g.expected(config, key, actual)
+	return expected != "" && expected != actual
+}
+
+// resolve 记录冲突并按策略处理，返回新的键和值
+func (g *typeGuard) resolve(config *Config, key string, value interface{}, actual FieldType) (string, interface{}) {
+	g.metrics.RecordTypeConflict(key)
+	return resolveConflict(config.TypeConflictPolicy, key, value, actual, g.expected(config, key, actual))
+}
+
+// expected 返回字段的期望类型，未知时返回空
+func (g *typeGuard) expected(config *Config, key string, actual FieldType) FieldType {
+	if t, ok := config.FieldTypes[key]; ok {
+		return t
+	}
+	if !config.InferFieldTypes {
+		return ""
+	}
+
+	if t, ok := g.inferred.Load(key); ok {
+		return t.(FieldType)
+	}
+	if g.count.Load() >= maxInferredFields {
+		return ""
+	}
+	t, loaded := g.inferred.LoadOrStore(key, actual)
+	if !loaded {
+		g.count.Add(1)
+	}
+	return t.(FieldType)
+}
+
+// resolveConflict 按策略处理冲突字段，返回新的键和值
+func resolveConflict(policy TypeConflictPolicy, key string, value interface{}, actual, expected FieldType) (string, interface{}) {
+	switch policy {
+	case TypeConflictRename:
+	case TypeConflictStringify:
+		if expected == FieldTypeString {
+			return key, stringify(value)
+		}
+		return key + FieldTypeString.suffix(), stringify(value)
+	default:
+		if coerced, ok := coerce(value, expected); ok {
+			return key, coerced
+		}
+	}
+	return key + actual.suffix(), value
+}
+
+// fieldTypeOf 返回值编码后对应的字段类型，nil、切片等无法判断的类型返回空
+// 结构体、map和指针按编码时的转换结果判断，如实现error、fmt.Stringer或encoding.TextMarshaler的结构体为字符串
+func fieldTypeOf(value interface{}) FieldType {
+	switch value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return FieldTypeString
+	case json.Number:
+		return FieldTypeNumber
+	}
+
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.String:
+		return FieldTypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return FieldTypeNumber
+	case reflect.Bool:
+		return FieldTypeBoolean
+	case reflect.Map, reflect.Struct, reflect.Pointer:
+		// 只需要顶层的类型，深度为1时不展开嵌套的值
+		normalized, err := normalizeValue(value, 1)
+		if err != nil {
+			return ""
+		}
+		return normalizedType(normalized)
+	default:
+		return ""
+	}
+}
+
+// normalizedType 返回转换后的字段值对应的字段类型
+func normalizedType(value interface{}) FieldType {
+	switch v := value.(type) {
+	case string, []byte:
+		// []byte编码为Base64字符串
+		return FieldTypeString
+	case bool:
+		return FieldTypeBoolean
+	case int64, uint64, float32, float64:
+		return FieldTypeNumber
+	case map[string]interface{}:
+		return FieldTypeObject
+	case json.RawMessage:
+		// json.Marshaler的输出按JSON的首字符判断
+		v = bytes.TrimLeft(v, " \t\r\n")
+		if len(v) == 0 {
+			return ""
+		}
+		switch c := v[0]; {
+		case c == '"':
+			return FieldTypeString
+		case c == '{':
+			return FieldTypeObject
+		case c == 't' || c == 'f':
+			return FieldTypeBoolean
+		case c == '-' || c >= '0' && c <= '9':
+			return FieldTypeNumber
+		}
+	}
+	return ""
+}
+
+// coerce 将值转换为期望类型
+func coerce(value interface{}, expected FieldType) (interface{}, bool) {
+	switch expected {
+	case FieldTypeString:
+		return stringify(value), true
+	case FieldTypeNumber:
+		s, ok := stringValue(value)
+		if !ok {
+			return nil, false
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, true
+		}
+	case FieldTypeBoolean:
+		s, ok := stringValue(value)
+		if !ok {
+			return nil, false
+		}
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// stringValue 返回字符串类型的值
+func stringValue(value interface{}) (string, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.String {
+		return "", false
+	}
+	return v.String(), true
+}
+
+// stringify 将值转为字符串，map和结构体编码为JSON
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	if fieldTypeOf(value) == FieldTypeObject {
+		if data, err := json.Marshal(value); err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
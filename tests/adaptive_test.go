@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+func newAdaptiveConfig() *elk.Config {
+	config := elk.DefaultConfig()
+	config.AdaptiveBatch = true
+	config.BatchSize = 100
+	config.MinBatchSize = 10
+	config.MaxBatchSize = 1000
+	config.BatchTimeout = 4 * time.Second
+	config.MinBatchTimeout = 1 * time.Second
+	config.MaxBatchTimeout = 16 * time.Second
+	config.TargetLatency = 100 * time.Millisecond
+	return config
+}
+
+func TestAdaptiveGrowOnBacklog(t *testing.T) {
+	config := newAdaptiveConfig()
+	metrics := elk.NewMetrics()
+	controller := elk.NewAdaptiveController(config, elk.NewBatch(config.BatchSize, config.BatchTimeout), metrics)
+
+	// 延迟正常且积压较多
+	controller.Observe(10*time.Millisecond, 500, nil)
+
+	size, timeout := controller.Current()
+	if size <= 100 {
+		t.Errorf("batch size = %d, want > 100", size)
+	}
+	if timeout >= 4*time.Second {
+		t.Errorf("batch timeout = %v, want < 4s", timeout)
+	}
+
+	snapshot := metrics.Snapshot()
+	if snapshot.BatchSize != int64(size) {
+		t.Errorf("metrics batch size = %d, want %d", snapshot.BatchSize, size)
+	}
+}
+
+func TestAdaptiveShrinkOnRejection(t *testing.T) {
+	config := newAdaptiveConfig()
+	controller := elk.NewAdaptiveController(config, elk.NewBatch(config.BatchSize, config.BatchTimeout), elk.NewMetrics())
+
+	rejected := &elk.BulkError{StatusCode: 429, Message: "too many requests", Rejected: true}
+	controller.Observe(10*time.Millisecond, 500, rejected)
+
+	size, _ := controller.Current()
+	if size != 50 {
+		t.Errorf("batch size = %d, want 50", size)
+	}
+	if controller.Backoff() != config.RetryInterval {
+		t.Errorf("backoff = %v, want %v", controller.Backoff(), config.RetryInterval)
+	}
+
+	// 连续拒绝时退避加倍，批量不低于下限
+	for i := 0; i < 10; i++ {
+		controller.Observe(10*time.Millisecond, 500, rejected)
+	}
+	size, timeout := controller.Current()
+	if size != config.MinBatchSize {
+		t.Errorf("batch size = %d, want %d", size, config.MinBatchSize)
+	}
+	if timeout != config.MaxBatchTimeout {
+		t.Errorf("batch timeout = %v, want %v", timeout, config.MaxBatchTimeout)
+	}
+	if controller.Backoff() != config.MaxRetryBackoff {
+		t.Errorf("backoff = %v, want %v", controller.Backoff(), config.MaxRetryBackoff)
+	}
+
+	// 恢复后清除退避
+	controller.Observe(10*time.Millisecond, 0, nil)
+	if controller.Backoff() != 0 {
+		t.Errorf("backoff after success = %v, want 0", controller.Backoff())
+	}
+}
+
+func TestAdaptiveShrinkOnLatency(t *testing.T) {
+	config := newAdaptiveConfig()
+	controller := elk.NewAdaptiveController(config, elk.NewBatch(config.BatchSize, config.BatchTimeout), elk.NewMetrics())
+
+	controller.Observe(time.Second, 500, nil)
+
+	size, _ := controller.Current()
+	if size >= 100 {
+		t.Errorf("batch size = %d, want < 100", size)
+	}
+	if controller.Backoff() != 0 {
+		t.Errorf("backoff = %v, want 0", controller.Backoff())
+	}
+}
+
+func TestAdaptiveIgnoresOtherErrors(t *testing.T) {
+	config := newAdaptiveConfig()
+	controller := elk.NewAdaptiveController(config, elk.NewBatch(config.BatchSize, config.BatchTimeout), elk.NewMetrics())
+
+	controller.Observe(time.Second, 500, errors.New("connection refused"))
+
+	size, timeout := controller.Current()
+	if size != 100 || timeout != 4*time.Second {
+		t.Errorf("params = (%d, %v), want unchanged", size, timeout)
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+// newTLSES 启动使用自签名证书的ES模拟服务，记录收到的Authorization头
+func newTLSES(t *testing.T, auth *string) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*auth = r.Header.Get("Authorization")
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSenderTLSWithCACertAndAPIKeyFile(t *testing.T) {
+	var auth string
+	server := newTLSES(t, &auth)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	config := elk.DefaultConfig()
+	config.ESAddresses = []string{server.URL}
+	config.ESCACert = writeFile(t, "ca.pem", caPEM)
+	config.ESAPIKeyFile = writeFile(t, "api_key", []byte("c2VjcmV0\n"))
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	if auth != "APIKey c2VjcmV0" {
+		t.Errorf("Authorization = %q, want ApiKey from file", auth)
+	}
+}
+
+func TestSenderTLSRejectsUnknownCA(t *testing.T) {
+	var auth string
+	server := newTLSES(t, &auth)
+
+	config := elk.DefaultConfig()
+	config.ESAddresses = []string{server.URL}
+
+	if _, err := elk.NewSender(config); err == nil {
+		t.Error("expected certificate error without ca cert")
+	}
+
+	config.ESInsecureSkipVerify = true
+	config.ESServiceToken = "token"
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender with insecure skip verify failed: %v", err)
+	}
+	defer sender.Close()
+
+	if auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want Bearer token", auth)
+	}
+}
+
+func TestSenderSecretFileErrors(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.ESPasswordFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := elk.NewSender(config); err == nil {
+		t.Error("expected error for missing password file")
+	}
+
+	config = elk.DefaultConfig()
+	config.ESCACert = writeFile(t, "ca.pem", []byte("not a certificate"))
+	if _, err := elk.NewSender(config); err == nil {
+		t.Error("expected error for invalid ca cert")
+	}
+}
+
+func TestConfigAuthValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(c *elk.Config)
+		expectErr bool
+	}{
+		{"password and password file", func(c *elk.Config) {
+			c.ESPassword = "secret"
+			c.ESPasswordFile = "/run/secrets/es"
+		}, true},
+		{"api key and service token", func(c *elk.Config) {
+			c.ESAPIKey = "key"
+			c.ESServiceToken = "token"
+		}, true},
+		{"client cert without key", func(c *elk.Config) { c.ESClientCert = "/etc/elk/client.pem" }, true},
+		{"cloud id without addresses", func(c *elk.Config) {
+			c.ESCloudID = "deployment:ZXhhbXBsZS5jb20kYWJjJGRlZg=="
+			c.ESAddresses = nil
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if tt.expectErr && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+		})
+	}
+}
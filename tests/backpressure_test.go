@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// fullQueue 发送链路被阻塞的客户端
+type fullQueue struct {
+	client  *elk.Client
+	server  *elktest.Server
+	release func() // 放行被阻塞的第一个批量请求
+}
+
+// newFullQueueClient 创建各通道容量为2的客户端，并让发送链路阻塞在第一个批量请求上
+// 返回时工作协程、等待队列和发送协程各持有一条日志，之后写入的日志只能留在通道中
+func newFullQueueClient(t *testing.T, policy elk.QueueFullPolicy) *fullQueue {
+	t.Helper()
+
+	blocked := make(chan struct{})
+	release := sync.OnceFunc(func() { close(blocked) })
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Wait: blocked})
+
+	config := server.NewConfig()
+	config.QueueFullPolicy = policy
+	config.EnqueueTimeout = 100 * time.Millisecond
+	config.QueueSize = 2
+	config.HighPriorityQueueSize = 2
+	config.LowPriorityQueueSize = 2
+	config.BatchSize = 1
+	config.BatchTimeout = time.Minute
+	config.WorkerCount = 1
+	config.SenderCount = 1
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() {
+		release()
+		client.Close()
+	})
+
+	// 逐条写入，避免工作协程取出前通道已满
+	for i := int64(1); i <= 3; i++ {
+		_ = client.Info("busy")
+		waitFor(t, "sender pipeline to block", func() bool {
+			return client.GetMetrics().PendingBatches == i
+		})
+	}
+	return &fullQueue{client: client, server: server, release: release}
+}
+
+// assertStored 放行发送链路并关闭客户端，检查写入和丢弃的日志
+func (q *fullQueue) assertStored(t *testing.T, kept, dropped []string) {
+	t.Helper()
+
+	q.release()
+	if err := q.client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	messages := q.server.Messages()
+	for _, message := range kept {
+		if !slices.Contains(messages, message) {
+			t.Errorf("%q was dropped, stored %v", message, messages)
+		}
+	}
+	for _, message := range dropped {
+		if slices.Contains(messages, message) {
+			t.Errorf("%q should be dropped, stored %v", message, messages)
+		}
+	}
+}
+
+func TestQueueFullDropNewest(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyDropNewest)
+	client := q.client
+
+	for _, message := range []string{"a", "b", "c"} {
+		if err := client.Info(message); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	if n := client.GetMetrics().DroppedNewestLogs; n != 1 {
+		t.Errorf("dropped newest = %d, want 1", n)
+	}
+	q.assertStored(t, []string{"a", "b"}, []string{"c"})
+}
+
+func TestQueueFullDropOldest(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyDropOldest)
+	client := q.client
+
+	// debug通道是最低优先级的通道，满后只能在通道内丢弃
+	for _, message := range []string{"a", "b", "c"} {
+		if err := client.Debug(message); err != nil {
+			t.Fatalf("Debug failed: %v", err)
+		}
+	}
+	if n := client.GetMetrics().DroppedOldestLogs; n != 1 {
+		t.Errorf("dropped oldest = %d, want 1", n)
+	}
+	q.assertStored(t, []string{"b", "c"}, []string{"a"})
+}
+
+func TestQueueFullDropOldestKeepsErrors(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyDropOldest)
+	client := q.client
+
+	_ = client.Debug("d1")
+	_ = client.Debug("d2")
+	_ = client.Error("e1")
+	_ = client.Error("e2")
+	_ = client.Info("n1")
+	_ = client.Info("n2")
+
+	// 高优先级通道满时挤掉最旧的debug日志，不丢弃已入队的error日志
+	_ = client.Error("e3")
+	// debug日志只挤掉debug日志，遇到进入低优先级通道的error日志时丢弃自身
+	_ = client.Debug("d3")
+	_ = client.Debug("d4")
+
+	metrics := client.GetMetrics()
+	if metrics.DroppedOldestLogs != 2 || metrics.DroppedNewestLogs != 1 {
+		t.Errorf("dropped oldest/newest = %d/%d, want 2/1", metrics.DroppedOldestLogs, metrics.DroppedNewestLogs)
+	}
+	q.assertStored(t, []string{"e1", "e2", "e3", "n1", "n2", "d3"}, []string{"d1", "d2", "d4"})
+}
+
+func TestQueueFullDropOldestDoesNotSpin(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyDropOldest)
+	client := q.client
+
+	// 高优先级和普通通道满后，error日志进入低优先级通道的空位
+	for _, message := range []string{"e1", "e2", "n1", "n2", "e3", "e4"} {
+		if message[0] == 'e' {
+			_ = client.Error(message)
+		} else {
+			_ = client.Info(message)
+		}
+	}
+
+	// 可丢弃的通道中只有更高级别的日志时直接丢弃新日志，不会忙等
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = client.Debug("noise")
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("drop_oldest kept retrying on a full queue")
+	}
+	if n := client.GetMetrics().DroppedNewestLogs; n != 100 {
+		t.Errorf("dropped newest = %d, want 100", n)
+	}
+	q.assertStored(t, []string{"e1", "e2", "e3", "e4"}, []string{"noise"})
+}
+
+func TestQueueFullDropByPriority(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyDropByPriority)
+	client := q.client
+
+	_ = client.Warn("w1")
+	_ = client.Warn("w2")
+
+	if err := client.Info("low"); err != nil {
+		t.Errorf("Info err = %v, want the entry dropped silently", err)
+	}
+	if err := client.Warn("w3"); !errors.Is(err, elk.ErrQueueFull) {
+		t.Errorf("Warn err = %v, want ErrQueueFull after EnqueueTimeout", err)
+	}
+
+	metrics := client.GetMetrics()
+	if metrics.DroppedPriorityLogs != 1 || metrics.DroppedTimeoutLogs != 1 {
+		t.Errorf("dropped priority/timeout = %d/%d, want 1/1", metrics.DroppedPriorityLogs, metrics.DroppedTimeoutLogs)
+	}
+	q.assertStored(t, []string{"w1", "w2"}, []string{"low", "w3"})
+}
+
+func TestQueueFullBlockTimeout(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyBlockTimeout)
+	client := q.client
+
+	_ = client.Info("a")
+	_ = client.Info("b")
+
+	start := time.Now()
+	err := client.Info("c")
+	if !errors.Is(err, elk.ErrQueueFull) {
+		t.Errorf("Info err = %v, want ErrQueueFull", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Info blocked %v, want about EnqueueTimeout", elapsed)
+	}
+
+	metrics := client.GetMetrics()
+	if metrics.BlockedLogs != 1 || metrics.DroppedTimeoutLogs != 1 {
+		t.Errorf("blocked/dropped timeout = %d/%d, want 1/1", metrics.BlockedLogs, metrics.DroppedTimeoutLogs)
+	}
+	q.assertStored(t, []string{"a", "b"}, []string{"c"})
+}
+
+func TestQueueFullBlock(t *testing.T) {
+	q := newFullQueueClient(t, elk.PolicyBlock)
+	client := q.client
+
+	_ = client.Info("a")
+	_ = client.Info("b")
+
+	// 阻塞到第一个批量请求完成、通道腾出位置为止
+	time.AfterFunc(50*time.Millisecond, q.release)
+	if err := client.Info("c"); err != nil {
+		t.Errorf("Info err = %v, want the entry enqueued after blocking", err)
+	}
+	if n := client.GetMetrics().BlockedLogs; n != 1 {
+		t.Errorf("blocked = %d, want 1", n)
+	}
+	q.assertStored(t, []string{"a", "b", "c"}, nil)
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -127,3 +128,64 @@ func TestBatchConcurrency(t *testing.T) {
 		t.Errorf("batch size = %d, want 1000", batch.Size())
 	}
 }
+
+func TestBatchFlushOnBytes(t *testing.T) {
+	batch := elk.NewBatch(1000, 10*time.Second)
+	batch.SetMaxBytes(4096)
+
+	// 单条大消息，条数远未达到上限
+	entry := elk.NewLogEntry(elk.LevelError, strings.Repeat("x", 2048), nil)
+	if batch.Add(entry) {
+		t.Error("should not flush before reaching max bytes")
+	}
+
+	if !batch.Add(entry.Clone()) {
+		t.Error("should flush when batch bytes exceed max bytes")
+	}
+
+	if batch.Bytes() < 4096 {
+		t.Errorf("batch bytes = %d, want >= 4096", batch.Bytes())
+	}
+
+	batch.Flush()
+	if batch.Bytes() != 0 {
+		t.Errorf("batch bytes after flush = %d, want 0", batch.Bytes())
+	}
+}
+
+func TestBatchUnlimitedBytes(t *testing.T) {
+	batch := elk.NewBatch(10, 10*time.Second)
+
+	// 未设置字节上限时只按条数刷新
+	entry := elk.NewLogEntry(elk.LevelInfo, strings.Repeat("x", 1<<20), nil)
+	if batch.Add(entry) {
+		t.Error("should not flush on bytes when max bytes is not set")
+	}
+}
+
+func TestBatchRecycleReusesBuffer(t *testing.T) {
+	batch := elk.NewBatch(4, time.Second)
+
+	batch.Add(elk.NewLogEntry(elk.LevelInfo, "first", nil))
+	first := batch.Flush()
+	batch.Recycle(first)
+	if first[:1][0] != nil {
+		t.Error("Recycle should clear entries so they can be garbage collected")
+	}
+
+	// Flush换上备用切片：第二个批次使用新建的切片，第三个批次复用归还的切片
+	batch.Add(elk.NewLogEntry(elk.LevelInfo, "second", nil))
+	second := batch.Flush()
+	batch.Add(elk.NewLogEntry(elk.LevelInfo, "third", nil))
+	third := batch.Flush()
+
+	if &second[0] == &first[:1][0] {
+		t.Error("buffer handed out by Flush should not be reused before Recycle")
+	}
+	if &third[0] != &first[:1][0] {
+		t.Error("recycled buffer should be reused by the next Flush")
+	}
+	if third[0].Message != "third" {
+		t.Errorf("third batch = %q, want third", third[0].Message)
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breaker := elk.NewCircuitBreaker(3, time.Second, nil)
+
+	for i := 0; i < 2; i++ {
+		breaker.RecordFailure()
+	}
+	if breaker.State() != elk.CircuitClosed {
+		t.Errorf("state = %s, want closed", breaker.State())
+	}
+
+	breaker.RecordFailure()
+	if breaker.State() != elk.CircuitOpen {
+		t.Errorf("state = %s, want open", breaker.State())
+	}
+
+	if breaker.Allow() {
+		t.Error("open breaker should not allow requests")
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	breaker := elk.NewCircuitBreaker(1, 50*time.Millisecond, nil)
+	breaker.RecordFailure()
+
+	time.Sleep(60 * time.Millisecond)
+
+	// 超时后只放行一次探测
+	if !breaker.Allow() {
+		t.Fatal("breaker should allow a probe after open timeout")
+	}
+	if breaker.State() != elk.CircuitHalfOpen {
+		t.Errorf("state = %s, want half-open", breaker.State())
+	}
+	if breaker.Allow() {
+		t.Error("half-open breaker should allow only one probe")
+	}
+
+	// 探测失败重新打开
+	breaker.RecordFailure()
+	if breaker.State() != elk.CircuitOpen {
+		t.Errorf("state = %s, want open", breaker.State())
+	}
+
+	// 探测成功关闭
+	time.Sleep(60 * time.Millisecond)
+	breaker.Allow()
+	breaker.RecordSuccess()
+	if breaker.State() != elk.CircuitClosed {
+		t.Errorf("state = %s, want closed", breaker.State())
+	}
+	if !breaker.Allow() {
+		t.Error("closed breaker should allow requests")
+	}
+}
+
+func TestCircuitBreakerCallback(t *testing.T) {
+	var mu sync.Mutex
+	var transitions []elk.CircuitState
+
+	breaker := elk.NewCircuitBreaker(1, time.Millisecond, func(from, to elk.CircuitState) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, to)
+	})
+
+	breaker.RecordFailure()
+	time.Sleep(5 * time.Millisecond)
+	breaker.Allow()
+	breaker.RecordSuccess()
+
+	want := []elk.CircuitState{elk.CircuitOpen, elk.CircuitHalfOpen, elk.CircuitClosed}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition[%d] = %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+func TestMetricsCircuitState(t *testing.T) {
+	metrics := elk.NewMetrics()
+
+	metrics.SetCircuitState(elk.CircuitOpen)
+	metrics.SetCircuitState(elk.CircuitHalfOpen)
+	metrics.SetCircuitState(elk.CircuitOpen)
+
+	snapshot := metrics.Snapshot()
+	if snapshot.CircuitState != "open" {
+		t.Errorf("CircuitState = %s, want open", snapshot.CircuitState)
+	}
+	if snapshot.CircuitOpens != 2 {
+		t.Errorf("CircuitOpens = %d, want 2", snapshot.CircuitOpens)
+	}
+}
@@ -0,0 +1,346 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func clusterTestConfig(primary, dr *elktest.Server) *elk.Config {
+	config := primary.NewConfig()
+	config.BatchSize = 1
+	config.Clusters = []elk.ClusterConfig{{
+		Name:         "dr",
+		ESAddresses:  []string{dr.URL},
+		IndexPattern: "dr-logs-{date}",
+	}}
+	return config
+}
+
+func TestClientFailoverToSecondaryCluster(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+	primary.SetDefault(elktest.Response{Status: http.StatusServiceUnavailable})
+
+	config := clusterTestConfig(primary, dr)
+	config.RetryCount = 0
+	config.CircuitBreakerThreshold = 1
+
+	config.FallbackHandler = func([]*elk.LogEntry, error) { t.Error("fallback should not be called") }
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := client.Info("hello", nil); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	waitFor(t, "logs to fail over", func() bool {
+		return client.GetMetrics().SuccessLogs == 3
+	})
+
+	if client.CircuitState() != elk.CircuitOpen {
+		t.Errorf("primary circuit = %v, want open", client.CircuitState())
+	}
+	// 熔断打开前，每个发送协程最多向主集群发送一次
+	if n := primary.BulkRequests(); n == 0 || n > config.SenderCount {
+		t.Errorf("primary received %d bulk requests, want at most %d before the circuit opened", n, config.SenderCount)
+	}
+	for _, doc := range dr.Documents() {
+		if !strings.HasPrefix(doc.Index, "dr-logs-") {
+			t.Errorf("secondary should use its own index pattern, got %s", doc.Index)
+		}
+	}
+
+	clusters := client.GetMetrics().Clusters
+	if stats := clusters["primary"]; stats.FailedLogs != 3 || stats.CircuitState != "open" {
+		t.Errorf("primary stats = %+v, want 3 failed and open circuit", stats)
+	}
+	if stats := clusters["dr"]; stats.SuccessLogs != 3 {
+		t.Errorf("dr stats = %+v, want 3 successful", stats)
+	}
+}
+
+func TestClientFailoverResendsOnlyFailedItems(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+	primary.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{0: elktest.ItemMappingError}})
+
+	config := primary.NewConfig()
+	config.BatchSize = 2
+	config.BatchTimeout = time.Minute
+	config.Clusters = []elk.ClusterConfig{{Name: "dr", ESAddresses: []string{dr.URL}}}
+	config.FallbackHandler = func([]*elk.LogEntry, error) { t.Error("fallback should not be called") }
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("bad")
+	_ = client.Info("good")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if messages := primary.Messages(); !slices.Equal(messages, []string{"good"}) {
+		t.Errorf("primary stored %v, want [good]", messages)
+	}
+	if messages := dr.Messages(); !slices.Equal(messages, []string{"bad"}) {
+		t.Errorf("dr stored %v, want only the entry the primary rejected", messages)
+	}
+
+	clusters := client.GetMetrics().Clusters
+	if stats := clusters["primary"]; stats.SuccessLogs != 1 || stats.FailedLogs != 1 {
+		t.Errorf("primary stats = %+v, want 1 successful and 1 failed", stats)
+	}
+	if stats := clusters["dr"]; stats.SuccessLogs != 1 {
+		t.Errorf("dr stats = %+v, want 1 successful", stats)
+	}
+}
+
+func TestClientReplicateToAllClusters(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+
+	config := clusterTestConfig(primary, dr)
+	config.ClusterMode = elk.ClusterModeReplicate
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := client.Info("hello", nil); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	waitFor(t, "logs to be replicated", func() bool {
+		return client.GetMetrics().SuccessLogs == 3
+	})
+
+	if primary.BulkRequests() != 3 || dr.BulkRequests() != 3 {
+		t.Errorf("bulk requests primary=%d dr=%d, want 3 each", primary.BulkRequests(), dr.BulkRequests())
+	}
+	for _, doc := range primary.Documents() {
+		if !strings.HasPrefix(doc.Index, "logs-") {
+			t.Errorf("primary should use the base index pattern, got %s", doc.Index)
+		}
+	}
+}
+
+func TestClientReplicateReportsFailedCluster(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+	dr.SetDefault(elktest.Response{Status: http.StatusBadRequest})
+
+	config := clusterTestConfig(primary, dr)
+	config.ClusterMode = elk.ClusterModeReplicate
+
+	var mu sync.Mutex
+	var failed []string
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		var clusterErr *elk.ClusterError
+		if errors.As(err, &clusterErr) {
+			mu.Lock()
+			failed = append(failed, clusterErr.Cluster)
+			mu.Unlock()
+		}
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Info("hello", nil); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	waitFor(t, "fallback", func() bool {
+		return client.GetMetrics().FailedLogs == 1
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0] != "dr" {
+		t.Errorf("failed clusters = %v, want [dr]", failed)
+	}
+	if n := client.GetMetrics().Clusters["primary"].SuccessLogs; n != 1 {
+		t.Errorf("primary success logs = %d, want 1", n)
+	}
+}
+
+func TestClientReplicateFallsBackOncePerEntry(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+	primary.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{0: elktest.ItemMappingError}})
+	dr.SetDefault(elktest.Response{Status: http.StatusBadRequest})
+
+	config := primary.NewConfig()
+	config.BatchSize = 2
+	config.BatchTimeout = time.Minute
+	config.ClusterMode = elk.ClusterModeReplicate
+	config.Clusters = []elk.ClusterConfig{{Name: "dr", ESAddresses: []string{dr.URL}}}
+
+	var mu sync.Mutex
+	var fallback []string
+	var fallbackErr error
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			fallback = append(fallback, entry.Message)
+		}
+		fallbackErr = err
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("bad")
+	_ = client.Info("good")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 两个集群都未写入的条目只交给降级处理一次，错误包含每个失败的集群
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(fallback, []string{"bad", "good"}) {
+		t.Errorf("fallback = %v, want each entry once", fallback)
+	}
+	for _, cluster := range []string{"primary", "dr"} {
+		if fallbackErr == nil || !strings.Contains(fallbackErr.Error(), "cluster "+cluster+":") {
+			t.Errorf("fallback err = %v, want it to name cluster %s", fallbackErr, cluster)
+		}
+	}
+	if messages := primary.Messages(); !slices.Equal(messages, []string{"good"}) {
+		t.Errorf("primary stored %v, want [good]", messages)
+	}
+	if n := client.GetMetrics().FallbackBatches; n != 1 {
+		t.Errorf("fallback calls = %d, want 1", n)
+	}
+}
+
+func TestClientReplicateLimitsEntriesOnce(t *testing.T) {
+	primary, dr := elktest.NewServer(t), elktest.NewServer(t)
+
+	config := primary.NewConfig()
+	config.MaxMessageBytes = 5
+	config.MaxDocumentBytes = 300
+	config.BatchSize = 3
+	config.BatchTimeout = time.Minute
+	config.ClusterMode = elk.ClusterModeReplicate
+	config.Clusters = []elk.ClusterConfig{{Name: "dr", ESAddresses: []string{dr.URL}}}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("short")
+	_ = client.Info("a long message")
+	_ = client.InfoTyped("rejected", elk.String("blob", strings.Repeat("b", 500)))
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 两个集群写入相同的截断后文档，截断和拒绝在发送到各集群之前只计数一次
+	for name, server := range map[string]*elktest.Server{"primary": primary, "dr": dr} {
+		if messages := server.Messages(); !slices.Equal(messages, []string{"short", "a lon"}) {
+			t.Errorf("%s stored %v, want [short a lon]", name, messages)
+		}
+	}
+	metrics := client.GetMetrics()
+	if metrics.TruncatedLogs != 1 || metrics.RejectedLogs != 1 {
+		t.Errorf("truncated = %d rejected = %d, want 1 and 1", metrics.TruncatedLogs, metrics.RejectedLogs)
+	}
+	if metrics.SuccessLogs != 2 {
+		t.Errorf("success = %d, want 2", metrics.SuccessLogs)
+	}
+}
+
+func TestConfigClusterValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(c *elk.Config)
+		expectErr bool
+	}{
+		{"unknown mode", func(c *elk.Config) { c.ClusterMode = "mirror" }, true},
+		{"missing name", func(c *elk.Config) {
+			c.Clusters = []elk.ClusterConfig{{ESAddresses: []string{"http://dr:9200"}}}
+		}, true},
+		{"duplicate name", func(c *elk.Config) {
+			c.Clusters = []elk.ClusterConfig{
+				{Name: "dr", ESAddresses: []string{"http://dr1:9200"}},
+				{Name: "dr", ESAddresses: []string{"http://dr2:9200"}},
+			}
+		}, true},
+		{"reserved name", func(c *elk.Config) {
+			c.Clusters = []elk.ClusterConfig{{Name: "primary", ESAddresses: []string{"http://dr:9200"}}}
+		}, true},
+		{"missing addresses", func(c *elk.Config) {
+			c.Clusters = []elk.ClusterConfig{{Name: "dr"}}
+		}, true},
+		{"bad index pattern", func(c *elk.Config) {
+			c.Clusters = []elk.ClusterConfig{{Name: "dr", ESAddresses: []string{"http://dr:9200"}, IndexPattern: "DR"}}
+		}, true},
+		{"valid replicate", func(c *elk.Config) {
+			c.ClusterMode = elk.ClusterModeReplicate
+			c.Clusters = []elk.ClusterConfig{{Name: "dr", ESAddresses: []string{"http://dr:9200"}, ESAPIKey: "key"}}
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if tt.expectErr && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigClusters(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+elasticsearch:
+  addresses:
+    - http://es-primary:9200
+clusters:
+  mode: replicate
+  targets:
+    - name: dr
+      addresses:
+        - http://es-dr:9200
+      api_key_file: /run/secrets/dr_api_key
+      index_pattern: "dr-{date}"
+`)
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.ClusterMode != elk.ClusterModeReplicate {
+		t.Errorf("ClusterMode = %q, want replicate", config.ClusterMode)
+	}
+	if len(config.Clusters) != 1 {
+		t.Fatalf("Clusters = %+v, want 1 cluster", config.Clusters)
+	}
+	dr := config.Clusters[0]
+	if dr.Name != "dr" || dr.ESAddresses[0] != "http://es-dr:9200" || dr.ESAPIKeyFile != "/run/secrets/dr_api_key" || dr.IndexPattern != "dr-{date}" {
+		t.Errorf("cluster = %+v", dr)
+	}
+}
@@ -1,10 +1,12 @@
 package tests
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -33,53 +35,180 @@ func TestDefaultConfig(t *testing.T) {
 }
 
 func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *elk.Config)
+		field  string // 期望出错的配置项，为空表示校验通过
+	}{
+		{"valid config", func(c *elk.Config) {}, ""},
+		{"empty es addresses", func(c *elk.Config) { c.ESAddresses = []string{} }, "es_addresses"},
+		{"invalid batch size", func(c *elk.Config) { c.BatchSize = 0 }, "batch_size"},
+		{"invalid queue size", func(c *elk.Config) { c.QueueSize = 0 }, "queue_size"},
+		{"negative max batch bytes", func(c *elk.Config) { c.MaxBatchBytes = -1 }, "max_batch_bytes"},
+		{"document bytes exceed batch bytes", func(c *elk.Config) {
+			c.MaxBatchBytes = 1024
+			c.MaxDocumentBytes = 2048
+		}, "max_document_bytes"},
+		{"invalid worker count", func(c *elk.Config) { c.WorkerCount = 0 }, "worker_count"},
+		{"empty index pattern", func(c *elk.Config) { c.IndexPattern = "" }, "index_pattern"},
+		{"zero flush interval", func(c *elk.Config) { c.FlushInterval = 0 }, "flush_interval"},
+		{"negative retry count", func(c *elk.Config) { c.RetryCount = -1 }, "retry_count"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("expected no error but got: %v", err)
+				}
+				return
+			}
+
+			var errs elk.ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected ValidationErrors, got %v", err)
+			}
+			if len(errs) != 1 || errs[0].Field() != tt.field {
+				t.Errorf("expected one error for %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestNewClientDefaultsNewSettings(t *testing.T) {
+	server := elktest.NewServer(t)
+
+	// 只设置原有字段的字面量配置，之后新增的配置项使用默认值
+	config := &elk.Config{
+		ESAddresses:   []string{server.URL},
+		IndexPattern:  "logs-{date}",
+		BatchSize:     100,
+		BatchTimeout:  time.Second,
+		FlushInterval: time.Second,
+		QueueSize:     1000,
+		WorkerCount:   4,
+	}
+	if err := config.Validate(); err == nil {
+		t.Fatal("Validate should report the unset settings")
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("literal")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if messages := server.Messages(); len(messages) != 1 {
+		t.Errorf("messages = %v, want the logged entry", messages)
+	}
+
+	// 调用方的配置保持不变
+	if config.SenderCount != 0 || config.HighPriorityQueueSize != 0 {
+		t.Errorf("sender count/high priority queue size = %d/%d, want the caller's config untouched",
+			config.SenderCount, config.HighPriorityQueueSize)
+	}
+}
+
+func TestConfigCustomization(t *testing.T) {
+	config := elk.DefaultConfig()
+
+	// 自定义配置
+	config.ESAddresses = []string{"http://es1:9200", "http://es2:9200"}
+	config.ServiceName = "test-service"
+	config.Environment = "test"
+	config.BatchSize = 500
+	config.BatchTimeout = 10 * time.Second
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("customized config should be valid: %v", err)
+	}
+
+	if config.ServiceName != "test-service" {
+		t.Errorf("ServiceName = %s, want test-service", config.ServiceName)
+	}
+
+	if config.Environment != "test" {
+		t.Errorf("Environment = %s, want test", config.Environment)
+	}
+}
+
+func TestConfigPriorityQueueSizes(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.HighPriorityQueueSize = 0
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for zero high priority queue size")
+	}
+
+	config = elk.DefaultConfig()
+	config.LowPriorityQueueSize = -1
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for negative low priority queue size")
+	}
+}
+
+func TestConfigLevelAndSampling(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MinLevel = "verbose"
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for unknown min level")
+	}
+
+	config = elk.DefaultConfig()
+	config.SampleRate = 1.5
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for sample rate above 1")
+	}
+
+	config = elk.DefaultConfig()
+	config.MinLevel = elk.LevelWarn
+	config.SampleRate = 0.1
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+}
+
+func TestConfigQueueFullPolicy(t *testing.T) {
 	tests := []struct {
 		name      string
-		config    *elk.Config
+		modify    func(c *elk.Config)
 		expectErr bool
 	}{
 		{
-			name:      "valid config",
-			config:    elk.DefaultConfig(),
+			name:      "drop oldest",
+			modify:    func(c *elk.Config) { c.QueueFullPolicy = elk.PolicyDropOldest },
 			expectErr: false,
 		},
 		{
-			name: "empty es addresses",
-			config: &elk.Config{
-				ESAddresses: []string{},
-				BatchSize:   100,
-				QueueSize:   1000,
-				WorkerCount: 4,
-			},
+			name:      "unknown policy",
+			modify:    func(c *elk.Config) { c.QueueFullPolicy = "drop_random" },
 			expectErr: true,
 		},
 		{
-			name: "invalid batch size",
-			config: &elk.Config{
-				ESAddresses: []string{"http://localhost:9200"},
-				BatchSize:   0,
-				QueueSize:   1000,
-				WorkerCount: 4,
+			name: "block timeout without timeout",
+			modify: func(c *elk.Config) {
+				c.QueueFullPolicy = elk.PolicyBlockTimeout
+				c.EnqueueTimeout = 0
 			},
 			expectErr: true,
 		},
 		{
-			name: "invalid queue size",
-			config: &elk.Config{
-				ESAddresses: []string{"http://localhost:9200"},
-				BatchSize:   100,
-				QueueSize:   0,
-				WorkerCount: 4,
+			name: "block without timeout",
+			modify: func(c *elk.Config) {
+				c.QueueFullPolicy = elk.PolicyBlock
+				c.EnqueueTimeout = 0
 			},
-			expectErr: true,
+			expectErr: false,
 		},
 		{
-			name: "invalid worker count",
-			config: &elk.Config{
-				ESAddresses: []string{"http://localhost:9200"},
-				BatchSize:   100,
-				QueueSize:   1000,
-				WorkerCount: 0,
+			name: "priority with unknown level",
+			modify: func(c *elk.Config) {
+				c.QueueFullPolicy = elk.PolicyDropByPriority
+				c.PriorityDropLevel = "trace"
 			},
 			expectErr: true,
 		},
@@ -87,7 +216,9 @@ func TestConfigValidation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := tt.config.Validate()
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			err := config.Validate()
 			if tt.expectErr && err == nil {
 				t.Error("expected error but got nil")
 			}
@@ -98,25 +229,82 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
-func TestConfigCustomization(t *testing.T) {
+func TestConfigCrossFieldValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(c *elk.Config)
+		expectErr bool
+	}{
+		{"zero flush interval", func(c *elk.Config) { c.FlushInterval = 0 }, true},
+		{"zero batch timeout", func(c *elk.Config) { c.BatchTimeout = 0 }, true},
+		{"address without scheme", func(c *elk.Config) { c.ESAddresses = []string{"localhost:9200"} }, true},
+		{"address with bad scheme", func(c *elk.Config) { c.ESAddresses = []string{"ftp://localhost:9200"} }, true},
+		{"https address", func(c *elk.Config) { c.ESAddresses = []string{"https://es.example.com:9243"} }, false},
+		{"uppercase index", func(c *elk.Config) { c.IndexPattern = "Logs-{date}" }, true},
+		{"index with forbidden char", func(c *elk.Config) { c.IndexPattern = "logs*{date}" }, true},
+		{"index with unknown placeholder", func(c *elk.Config) { c.IndexPattern = "logs-{service}" }, true},
+		{"index starting with underscore", func(c *elk.Config) { c.IndexPattern = "_logs" }, true},
+		{"index with year and month", func(c *elk.Config) { c.IndexPattern = "logs-{year}.{month}" }, false},
+		{"negative retry count", func(c *elk.Config) { c.RetryCount = -1 }, true},
+		{"backoff below interval", func(c *elk.Config) {
+			c.RetryInterval = 10 * time.Second
+			c.MaxRetryBackoff = time.Second
+		}, true},
+		{"queue smaller than batch", func(c *elk.Config) {
+			c.AdaptiveBatch = false
+			c.BatchSize = 500
+			c.QueueSize = 100
+		}, true},
+		{"too many workers", func(c *elk.Config) { c.WorkerCount = 100000 }, true},
+		{"too many senders", func(c *elk.Config) { c.SenderCount = 100000 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			err := config.Validate()
+			if tt.expectErr && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigValidationReportsAllErrors(t *testing.T) {
 	config := elk.DefaultConfig()
+	config.FlushInterval = 0
+	config.IndexPattern = "Logs"
+	config.RetryCount = -1
 
-	// 自定义配置
-	config.ESAddresses = []string{"http://es1:9200", "http://es2:9200"}
-	config.ServiceName = "test-service"
-	config.Environment = "test"
-	config.BatchSize = 500
-	config.BatchTimeout = 10 * time.Second
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected error but got nil")
+	}
 
-	if err := config.Validate(); err != nil {
-		t.Errorf("customized config should be valid: %v", err)
+	var errs elk.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %T", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), err)
 	}
 
-	if config.ServiceName != "test-service" {
-		t.Errorf("ServiceName = %s, want test-service", config.ServiceName)
+	fields := map[string]bool{}
+	for _, e := range errs {
+		fields[e.Field()] = true
+	}
+	for _, field := range []string{"flush_interval", "index_pattern", "retry_count"} {
+		if !fields[field] {
+			t.Errorf("missing error for %s in %v", field, err)
+		}
 	}
 
-	if config.Environment != "test" {
-		t.Errorf("Environment = %s, want test", config.Environment)
+	var invalid elk.ErrInvalidConfig
+	if !errors.As(err, &invalid) {
+		t.Error("errors.As should find ErrInvalidConfig")
 	}
 }
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+// lookup 按点分路径读取嵌套JSON对象中的值
+func lookup(doc map[string]interface{}, path string) interface{} {
+	var cur interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[key]
+	}
+	return cur
+}
+
+func TestLogEntryToECS(t *testing.T) {
+	entry := elk.NewLogEntry(elk.LevelError, "request failed", elk.Fields{
+		"user_id": 123,
+		"log":     "custom field must not replace the ECS log object",
+	})
+	entry.ServiceName = "order-service"
+	entry.Environment = "production"
+	entry.Logger = "http"
+	entry.Caller = "/app/handler/order.go:42"
+	entry.Stack = "goroutine 1 [running]:"
+	entry.HostName = "web-1"
+	entry.IP = "10.0.0.1"
+
+	data, err := entry.ToECS()
+	if err != nil {
+		t.Fatalf("ToECS failed: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"@timestamp":           entry.Timestamp.Format("2006-01-02T15:04:05.999999999Z07:00"),
+		"message":              "request failed",
+		"ecs.version":          elk.ECSVersion,
+		"log.level":            "error",
+		"log.logger":           "http",
+		"log.origin.file.name": "/app/handler/order.go",
+		"log.origin.file.line": float64(42),
+		"service.name":         "order-service",
+		"service.environment":  "production",
+		"host.name":            "web-1",
+		"host.ip":              "10.0.0.1",
+		"error.stack_trace":    "goroutine 1 [running]:",
+		"user_id":              float64(123),
+	}
+	for path, want := range expected {
+		if got := lookup(doc, path); got != want {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+
+	for _, legacy := range []string{"level", "environment", "caller", "stack"} {
+		if _, ok := doc[legacy]; ok {
+			t.Errorf("ECS document should not contain legacy field %q", legacy)
+		}
+	}
+}
+
+func TestLogEntryToECSErrField(t *testing.T) {
+	pathErr := &fs.PathError{Op: "open", Path: "/etc/app.yaml", Err: fs.ErrNotExist}
+
+	tests := []struct {
+		name   string
+		fields []elk.Field
+		extra  elk.Fields
+	}{
+		{name: "streamed", fields: []elk.Field{elk.Err(pathErr)}},
+		// 同名字段使用map编码
+		{name: "map", fields: []elk.Field{elk.Err(pathErr), elk.Int("n", 1)}, extra: elk.Fields{"n": 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := elk.NewLogEntry(elk.LevelError, "load config", tt.extra)
+			entry.TypedFields = tt.fields
+			entry.Stack = "goroutine 1 [running]:"
+
+			data, err := entry.ToECS()
+			if err != nil {
+				t.Fatalf("ToECS failed: %v", err)
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("Failed to unmarshal JSON: %v", err)
+			}
+
+			// ECS中error是对象，Err写入error.message和error.type，与堆栈放在一起
+			expected := map[string]interface{}{
+				"error.message":     pathErr.Error(),
+				"error.type":        "*fs.PathError",
+				"error.stack_trace": "goroutine 1 [running]:",
+			}
+			for path, want := range expected {
+				if got := lookup(doc, path); got != want {
+					t.Errorf("%s = %v, want %v", path, got, want)
+				}
+			}
+			if _, ok := doc["custom_error"]; ok {
+				t.Errorf("Err should not be renamed to avoid the error object: %s", data)
+			}
+		})
+	}
+}
+
+func TestSenderECSMarkers(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.DocumentFormat = elk.FormatECS
+	config.MaxFieldBytes = 4
+
+	entries := []*elk.LogEntry{
+		elk.NewLogEntry(elk.LevelError, "truncated error", nil),
+		elk.NewLogEntry(elk.LevelInfo, "unencodable", elk.Fields{"ch": make(chan int)}),
+	}
+	entries[0].TypedFields = []elk.Field{elk.Err(errors.New("connection refused"))}
+
+	docs := sendDocuments(t, config, entries...)
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2", len(docs))
+	}
+
+	// 截断后的Err仍然写入error对象
+	if got := lookup(docs[0], "error.message"); got != "conn" {
+		t.Errorf("error.message = %v, want the truncated message", got)
+	}
+	if got := lookup(docs[0], "error.type"); got != "*errors.errorString" {
+		t.Errorf("error.type = %v, want *errors.errorString", got)
+	}
+	if lookup(docs[0], "elk_logger.truncated") != true {
+		t.Errorf("elk_logger = %v, want truncation marker", docs[0]["elk_logger"])
+	}
+
+	if encErr, _ := lookup(docs[1], "elk_logger.encoding_error").(string); !strings.Contains(encErr, "chan int") {
+		t.Errorf("elk_logger.encoding_error = %q, want the encoding error", encErr)
+	}
+	if log, _ := docs[1]["log"].(map[string]interface{}); len(log) != 1 {
+		t.Errorf("log = %v, should only contain ECS fields", log)
+	}
+}
+
+func TestLogEntryToECSCallerWithoutLine(t *testing.T) {
+	entry := elk.NewLogEntry(elk.LevelInfo, "hello", nil)
+	entry.Caller = "unknown"
+
+	data, err := entry.ToECS()
+	if err != nil {
+		t.Fatalf("ToECS failed: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if got := lookup(doc, "log.origin.file.name"); got != "unknown" {
+		t.Errorf("log.origin.file.name = %v, want unknown", got)
+	}
+	if got := lookup(doc, "log.origin.file.line"); got != nil {
+		t.Errorf("log.origin.file.line = %v, want absent", got)
+	}
+}
+
+func TestSenderECSFormat(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.DocumentFormat = elk.FormatECS
+
+	docs := sendDocuments(t, config, newEntries("hello")...)
+	if len(docs) != 1 || lookup(docs[0], "ecs.version") != elk.ECSVersion {
+		t.Errorf("documents should use the ECS format, got %v", docs)
+	}
+}
+
+func TestConfigDocumentFormat(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.DocumentFormat = "otel"
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for unknown document format")
+	}
+
+	config.DocumentFormat = elk.FormatECS
+	if err := config.Validate(); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+}
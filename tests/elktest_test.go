@@ -0,0 +1,309 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestElktestClientEndToEnd(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+	config.ServiceName = "orders"
+	config.IndexPattern = "app-{date}"
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := client.InfoTyped("order created", elk.Int("n", i), elk.String("channel", "web")); err != nil {
+			t.Fatalf("InfoTyped failed: %v", err)
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	docs := server.Documents()
+	if len(docs) != 5 {
+		t.Fatalf("got %d documents, want 5", len(docs))
+	}
+	index := "app-" + time.Now().Format("2006.01.02")
+	var seen []int
+	for _, doc := range docs {
+		if doc.Index != index || doc.ID == "" {
+			t.Errorf("document index = %q id = %q, want %q", doc.Index, doc.ID, index)
+		}
+		if doc.Source["service.name"] != "orders" || doc.Source["channel"] != "web" {
+			t.Errorf("document = %v", doc.Source)
+		}
+		seen = append(seen, int(doc.Source["n"].(float64)))
+	}
+	slices.Sort(seen)
+	if !slices.Equal(seen, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("n = %v, want 0..4", seen)
+	}
+
+	metrics := client.GetMetrics()
+	if metrics.TotalLogs != 5 || metrics.FailedLogs != 0 {
+		t.Errorf("total = %d failed = %d, want 5 and 0", metrics.TotalLogs, metrics.FailedLogs)
+	}
+}
+
+func TestElktestWaitForDocuments(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+	config.FlushOnError = true
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.ErrorTyped("payment failed", elk.Err(io.ErrUnexpectedEOF)); err != nil {
+		t.Fatalf("ErrorTyped failed: %v", err)
+	}
+	if !server.WaitForDocuments(1, 5*time.Second) {
+		t.Fatal("error log was not flushed immediately")
+	}
+	if doc := server.Documents()[0].Source; doc["error"] != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("error = %v", doc["error"])
+	}
+}
+
+func TestElktestRetriesTooManyRequests(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(
+		elktest.Response{Status: http.StatusTooManyRequests},
+		elktest.Response{Status: http.StatusServiceUnavailable},
+	)
+	sender := newSender(t, server.NewConfig())
+
+	if err := sender.SendWithRetry(context.Background(), newEntries("first", "second")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+	if n := server.BulkRequests(); n != 3 {
+		t.Errorf("bulk requests = %d, want 3", n)
+	}
+	if messages := server.Messages(); !slices.Equal(messages, []string{"first", "second"}) {
+		t.Errorf("messages = %v", messages)
+	}
+}
+
+func TestElktestPartialRejection(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{1: elktest.ItemRejected}})
+	sender := newSender(t, server.NewConfig())
+
+	if err := sender.SendWithRetry(context.Background(), newEntries("first", "second", "third")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+
+	// 只重发被拒绝的条目
+	if messages := server.Messages(); !slices.Equal(messages, []string{"first", "third", "second"}) {
+		t.Errorf("messages = %v, want the rejected entry resent last", messages)
+	}
+	if n := server.BulkRequests(); n != 2 {
+		t.Errorf("bulk requests = %d, want 2", n)
+	}
+}
+
+func TestElktestMappingErrorNotRetried(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{0: elktest.ItemMappingError}})
+	sender := newSender(t, server.NewConfig())
+
+	err := sender.SendWithRetry(context.Background(), newEntries("bad", "good"))
+	var sendErr *elk.SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("err = %v, want *SendError", err)
+	}
+	if sendErr.Err != nil || len(sendErr.Failed) != 1 {
+		t.Fatalf("SendError = %+v, want one item-level failure", sendErr)
+	}
+	if item := sendErr.Failed[0]; item.Entry.Message != "bad" || item.Status != http.StatusBadRequest || item.Type != "mapper_parsing_exception" {
+		t.Errorf("failed item = %+v, want the bad entry with its mapping error", item)
+	}
+	if n := server.BulkRequests(); n != 1 {
+		t.Errorf("bulk requests = %d, want 1 (mapping errors are not retryable)", n)
+	}
+	if messages := server.Messages(); !slices.Equal(messages, []string{"good"}) {
+		t.Errorf("messages = %v, want only the accepted entry", messages)
+	}
+}
+
+func TestElktestClientFallbackOnlyFailedItems(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{1: elktest.ItemMappingError}})
+
+	var mu sync.Mutex
+	var fallback []string
+	var fallbackErr error
+	config := server.NewConfig()
+	config.BatchSize = 3
+	config.BatchTimeout = time.Minute
+	config.CircuitBreakerThreshold = 1
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			fallback = append(fallback, entry.Message)
+		}
+		fallbackErr = err
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	for _, message := range []string{"first", "second", "third"} {
+		if err := client.Info(message); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if messages := server.Messages(); !slices.Equal(messages, []string{"first", "third"}) {
+		t.Errorf("stored = %v, want the two accepted entries", messages)
+	}
+	// 已写入的条目不交给降级处理，避免重复
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(fallback, []string{"second"}) {
+		t.Errorf("fallback = %v, want only the rejected entry", fallback)
+	}
+	var sendErr *elk.SendError
+	if !errors.As(fallbackErr, &sendErr) || sendErr.Failed[0].Type != "mapper_parsing_exception" {
+		t.Errorf("fallback err = %v, want *SendError with the mapping error", fallbackErr)
+	}
+	// 映射错误与集群状态无关，不触发熔断
+	if state := client.CircuitState(); state != elk.CircuitClosed {
+		t.Errorf("circuit = %v, want closed after an item-level 4xx", state)
+	}
+	if server.BulkRequests() != 1 {
+		t.Errorf("bulk requests = %d, want 1", server.BulkRequests())
+	}
+}
+
+func TestElktestLatencyTimeout(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Latency: 5 * time.Second})
+	config := server.NewConfig()
+	config.RequestTimeout = 50 * time.Millisecond
+	sender := newSender(t, config)
+
+	start := time.Now()
+	if err := sender.SendWithRetry(context.Background(), newEntries("slow")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SendWithRetry took %v, want the slow request to time out", elapsed)
+	}
+	if n := server.BulkRequests(); n != 2 || len(server.Documents()) != 1 {
+		t.Errorf("bulk requests = %d documents = %d, want 2 and 1", n, len(server.Documents()))
+	}
+}
+
+func TestElktestConnectionReset(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Reset: true})
+	sender := newSender(t, server.NewConfig())
+
+	if err := sender.SendWithRetry(context.Background(), newEntries("reset")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+	if n := server.BulkRequests(); n != 2 || len(server.Documents()) != 1 {
+		t.Errorf("bulk requests = %d documents = %d, want 2 and 1", n, len(server.Documents()))
+	}
+}
+
+func TestElktestClientFallbackOnOutage(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Status: http.StatusServiceUnavailable})
+
+	var mu sync.Mutex
+	var fallback []string
+	config := server.NewConfig()
+	config.RetryCount = 1
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			fallback = append(fallback, entry.Message)
+		}
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("one")
+	_ = client.Info("two")
+	_ = client.Close()
+
+	slices.Sort(fallback)
+	if !slices.Equal(fallback, []string{"one", "two"}) {
+		t.Errorf("fallback = %v, want both entries", fallback)
+	}
+	if len(server.Documents()) != 0 {
+		t.Errorf("got %d documents during outage, want 0", len(server.Documents()))
+	}
+
+	// 恢复后继续写入
+	server.Reset()
+	sender := newSender(t, server.NewConfig())
+	if err := sender.SendWithRetry(context.Background(), newEntries("recovered")); err != nil {
+		t.Fatalf("SendWithRetry after recovery failed: %v", err)
+	}
+}
+
+func TestElktestTemplates(t *testing.T) {
+	server := elktest.NewServer(t)
+	url := server.URL + "/_index_template/logs"
+
+	req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(`{"index_patterns":["logs-*"]}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT template failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT template status = %d", res.StatusCode)
+	}
+
+	template, ok := server.Template("_index_template", "logs")
+	if !ok || !strings.Contains(string(template), "logs-*") {
+		t.Errorf("template = %s, %v", template, ok)
+	}
+
+	res, err = http.Get(url)
+	if err != nil {
+		t.Fatalf("GET template failed: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if !strings.Contains(string(body), `"index_templates":[{"name":"logs"`) {
+		t.Errorf("GET template = %s", body)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, url, nil)
+	if res, err = http.DefaultClient.Do(req); err == nil {
+		res.Body.Close()
+	}
+	if res, err = http.Head(url); err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("HEAD deleted template = %v, %v, want 404", res, err)
+	}
+}
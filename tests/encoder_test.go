@@ -0,0 +1,268 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// benchEntry 典型的业务日志
+func benchEntry() *elk.LogEntry {
+	return &elk.LogEntry{
+		Timestamp:   time.Now(),
+		Level:       elk.LevelInfo,
+		Message:     "order created successfully",
+		Logger:      "order",
+		Caller:      "/app/service/order.go:128",
+		ServiceName: "order-service",
+		Environment: "production",
+		HostName:    "web-1",
+		IP:          "10.0.0.1",
+		Fields: elk.Fields{
+			"user_id":     12345,
+			"order_id":    "ORD-20240102-0001",
+			"amount":      99.5,
+			"paid":        true,
+			"duration_ms": int64(37),
+		},
+	}
+}
+
+// mapToJSON 旧版本的编码方式：构建map后使用encoding/json，作为基准和对照
+func mapToJSON(l *elk.LogEntry) ([]byte, error) {
+	data := map[string]interface{}{
+		"@timestamp": l.Timestamp.Format(time.RFC3339Nano),
+		"level":      l.Level,
+		"message":    l.Message,
+	}
+	optional := map[string]string{
+		"logger": l.Logger, "caller": l.Caller, "stack": l.Stack, "service.name": l.ServiceName,
+		"environment": l.Environment, "host.name": l.HostName, "host.ip": l.IP,
+	}
+	for k, v := range optional {
+		if v != "" {
+			data[k] = v
+		}
+	}
+	for k, v := range l.Fields {
+		data[k] = v
+	}
+	return json.Marshal(data)
+}
+
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return doc
+}
+
+func TestToJSONMatchesEncodingJSON(t *testing.T) {
+	entry := benchEntry()
+	entry.Message = "quote \" backslash \\ newline \n tab \t ctrl \x01 html <a&b> invalid \xff line   中文"
+	entry.Stack = "goroutine 1 [running]:\n\tmain.go:1"
+	entry.Fields = elk.Fields{
+		"int8":     int8(-8),
+		"uint64":   uint64(math.MaxUint64),
+		"float32":  float32(1.5),
+		"small":    1e-7,
+		"large":    1e21,
+		"whole":    3.0,
+		"negative": -0.25,
+		"nil":      nil,
+		"time":     time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		"duration": 1500 * time.Millisecond,
+		"nested":   map[string]interface{}{"a": []int{1, 2}},
+		"list":     []string{"x", "y"},
+		"bytes":    []byte("raw"),
+		"key\"esc": "v",
+	}
+
+	got, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	want, err := mapToJSON(entry)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+
+	if g, w := decode(t, got), decode(t, want); !reflect.DeepEqual(g, w) {
+		t.Errorf("streaming encoder output differs\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestToECSMatchesMapEncoding(t *testing.T) {
+	entry := benchEntry()
+	entry.Stack = "stack"
+	entry.Fields["log"] = "collides with ECS log object"
+
+	// 展开点分键时使用map编码，内容应与流式编码一致
+	config := elk.DefaultConfig()
+	config.DocumentFormat = elk.FormatECS
+	config.ExpandDottedKeys = true
+	expanded := sendDocuments(t, config, entry)[0]
+
+	data, err := entry.ToECS()
+	if err != nil {
+		t.Fatalf("ToECS failed: %v", err)
+	}
+	if streamed := decode(t, data); !reflect.DeepEqual(streamed, expanded) {
+		t.Errorf("ToECS = %v\nwant %v", streamed, expanded)
+	}
+}
+
+func TestToJSONUnsupportedValue(t *testing.T) {
+	entry := elk.NewLogEntry(elk.LevelInfo, "nan", elk.Fields{"ratio": math.NaN(), "inf": math.Inf(1)})
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed for NaN: %v", err)
+	}
+	if doc := decode(t, data); doc["ratio"] != nil || doc["inf"] != nil {
+		t.Errorf("NaN and Inf = %v %v, want null", doc["ratio"], doc["inf"])
+	}
+
+	entry = elk.NewLogEntry(elk.LevelInfo, "chan", elk.Fields{"ch": make(chan int)})
+	if _, err := entry.ToJSON(); err == nil {
+		t.Error("ToJSON should fail for channels")
+	}
+}
+
+func TestToJSONAllocations(t *testing.T) {
+	entry := benchEntry()
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = entry.ToJSON()
+	})
+	if allocs > 1 {
+		t.Errorf("ToJSON allocs = %v, want at most 1", allocs)
+	}
+}
+
+// typedFieldSets 强类型字段组合，包括顶层键为保留名称、需要判断冲突的字段
+var typedFieldSets = []struct {
+	name   string
+	fields []elk.Field
+}{
+	{"int", []elk.Field{elk.Int("n", 1)}},
+	{"err", []elk.Field{elk.Err(io.ErrUnexpectedEOF)}},
+	{"reserved_namespaces", []elk.Field{elk.Int("host.x", 1), elk.Int("message_id", 1), elk.Int("log.x", 1)}},
+}
+
+// typedBenchEntry 带有给定强类型字段的典型业务日志
+func typedBenchEntry(fields []elk.Field) *elk.LogEntry {
+	entry := benchEntry()
+	entry.TypedFields = fields
+	return entry
+}
+
+func TestReservedKeysStayStreamed(t *testing.T) {
+	// 保留名称下的键只和内置字段比较，分配次数与普通字段相同；
+	// ECS格式的Err字段移入error对象时复制条目并取得错误类型名
+	limits := map[string][2]float64{
+		"int":                 {1, 2},
+		"err":                 {1, 5},
+		"reserved_namespaces": {1, 2},
+	}
+	for _, set := range typedFieldSets {
+		entry := typedBenchEntry(set.fields)
+		limit := limits[set.name]
+
+		if allocs := testing.AllocsPerRun(100, func() { _, _ = entry.ToJSON() }); allocs > limit[0] {
+			t.Errorf("%s: ToJSON allocs = %v, want at most %v", set.name, allocs, limit[0])
+		}
+		if allocs := testing.AllocsPerRun(100, func() { _, _ = entry.ToECS() }); allocs > limit[1] {
+			t.Errorf("%s: ToECS allocs = %v, want at most %v", set.name, allocs, limit[1])
+		}
+	}
+}
+
+func BenchmarkLogEntryToJSON(b *testing.B) {
+	entry := benchEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := entry.ToJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogEntryToJSONTypedFields(b *testing.B) {
+	for _, set := range typedFieldSets {
+		b.Run(set.name, func(b *testing.B) {
+			entry := typedBenchEntry(set.fields)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := entry.ToJSON(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLogEntryToJSONMap(b *testing.B) {
+	entry := benchEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mapToJSON(entry); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogEntryToECS(b *testing.B) {
+	entry := benchEntry()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := entry.ToECS(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogEntryToECSTypedFields(b *testing.B) {
+	for _, set := range typedFieldSets {
+		b.Run(set.name, func(b *testing.B) {
+			entry := typedBenchEntry(set.fields)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := entry.ToECS(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSenderSend 每次发送1000条日志的批次
+func BenchmarkSenderSend(b *testing.B) {
+	config := elktest.NewServer(b).NewConfig()
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sender.Close()
+
+	entries := make([]*elk.LogEntry, 1000)
+	for i := range entries {
+		entries[i] = benchEntry()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sender.Send(context.Background(), entries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestClientFatalSendsSynchronously(t *testing.T) {
+	server := elktest.NewServer(t)
+
+	config := server.NewConfig()
+	config.BatchSize = 100
+	config.BatchTimeout = time.Minute
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Info("queued"); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if err := client.FatalTyped("shutting down", elk.String("reason", "oom")); err != nil {
+		t.Fatalf("FatalTyped failed: %v", err)
+	}
+
+	// Fatal不经过队列和批次，返回时已经写入；普通日志仍在批次中等待
+	docs := server.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("stored %d documents after Fatal, want only the fatal entry", len(docs))
+	}
+	if docs[0].Source["message"] != "shutting down" || docs[0].Source["level"] != string(elk.LevelFatal) {
+		t.Errorf("document = %v, want the fatal entry", docs[0].Source)
+	}
+	if n := client.GetMetrics().TotalLogs; n != 2 {
+		t.Errorf("total logs = %d, want 2", n)
+	}
+}
+
+func TestClientFatalRespectsFlushTimeout(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Latency: 5 * time.Second})
+
+	var mu sync.Mutex
+	var fallback []string
+	config := server.NewConfig()
+	config.FatalFlushTimeout = 200 * time.Millisecond
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			fallback = append(fallback, entry.Message)
+		}
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	start := time.Now()
+	err = client.Fatal("unreachable")
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fatal err = %v, want deadline exceeded", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Fatal took %v, want it bounded by FatalFlushTimeout", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fallback) != 1 || fallback[0] != "unreachable" {
+		t.Errorf("fallback = %v, want the fatal entry", fallback)
+	}
+}
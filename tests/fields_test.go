@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func collidingEntry() *elk.LogEntry {
+	return &elk.LogEntry{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     elk.LevelInfo,
+		Message:   "real message",
+		Fields: elk.Fields{
+			"message":    "user message",
+			"level":      "user level",
+			"@timestamp": "user timestamp",
+			"user_id":    42,
+		},
+	}
+}
+
+func TestFieldCollisionPrefix(t *testing.T) {
+	docs := sendDocuments(t, elk.DefaultConfig(), collidingEntry())
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	doc := docs[0]
+
+	expected := map[string]interface{}{
+		"message":           "real message",
+		"level":             "info",
+		"@timestamp":        "2024-01-02T03:04:05Z",
+		"custom_message":    "user message",
+		"custom_level":      "user level",
+		"custom_@timestamp": "user timestamp",
+		"user_id":           float64(42),
+	}
+	for key, want := range expected {
+		if got := doc[key]; got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestFieldCollisionOverwrite(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldCollision = elk.CollisionOverwrite
+
+	doc := sendDocuments(t, config, collidingEntry())[0]
+	if doc["message"] != "user message" {
+		t.Errorf("message = %v, want user field to overwrite it", doc["message"])
+	}
+}
+
+func TestFieldCollisionRejectInSender(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldCollision = elk.CollisionReject
+
+	ok := &elk.LogEntry{Timestamp: time.Now(), Level: elk.LevelInfo, Message: "ok", Fields: elk.Fields{"user_id": 1}}
+	docs := sendDocuments(t, config, collidingEntry(), ok)
+
+	// 冲突的日志被单独拒绝，不影响同批次的其他日志
+	if len(docs) != 1 || docs[0]["message"] != "ok" {
+		t.Errorf("documents = %v, want only the non-colliding entry", docs)
+	}
+}
+
+func TestClientFieldCollisionReject(t *testing.T) {
+	config := elktest.NewServer(t).NewConfig()
+	config.FieldCollision = elk.CollisionReject
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Info("hello", elk.Fields{"level": "custom"})
+	if !errors.Is(err, elk.ErrFieldCollision) {
+		t.Errorf("Info err = %v, want ErrFieldCollision", err)
+	}
+	if err := client.Info("hello", elk.Fields{"user_id": 1}); err != nil {
+		t.Errorf("Info without collision failed: %v", err)
+	}
+	if got := client.GetMetrics().RejectedLogs; got != 1 {
+		t.Errorf("RejectedLogs = %d, want 1", got)
+	}
+}
+
+func TestFieldsNamespace(t *testing.T) {
+	for _, format := range []elk.DocumentFormat{elk.FormatLegacy, elk.FormatECS} {
+		t.Run(string(format), func(t *testing.T) {
+			config := elk.DefaultConfig()
+			config.DocumentFormat = format
+			config.FieldsNamespace = "labels"
+
+			doc := sendDocuments(t, config, collidingEntry())[0]
+			if doc["message"] != "real message" {
+				t.Errorf("message = %v, want real message", doc["message"])
+			}
+			if got := lookup(doc, "labels.message"); got != "user message" {
+				t.Errorf("labels.message = %v, want user message", got)
+			}
+			if got := lookup(doc, "labels.user_id"); got != float64(42) {
+				t.Errorf("labels.user_id = %v, want 42", got)
+			}
+			if _, ok := doc["user_id"]; ok {
+				t.Error("custom fields should not be written to the top level")
+			}
+		})
+	}
+}
+
+func TestExpandDottedKeys(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.ExpandDottedKeys = true
+	config.ServiceName = "order-service"
+
+	entry := &elk.LogEntry{
+		Timestamp:   time.Now(),
+		Level:       elk.LevelInfo,
+		Message:     "request",
+		ServiceName: "order-service",
+		Fields: elk.Fields{
+			"http.request.method": "GET",
+			"http.response.code":  200,
+			"service.version":     "1.2.0",
+			"service.name":        "other",
+		},
+	}
+	doc := sendDocuments(t, config, entry)[0]
+
+	expected := map[string]interface{}{
+		"http.request.method": "GET",
+		"http.response.code":  float64(200),
+		"service.name":        "order-service",
+		"service.version":     "1.2.0",
+		"custom_service.name": "other",
+	}
+	for path, want := range expected {
+		if got := lookup(doc, path); got != want {
+			t.Errorf("%s = %v, want %v", path, got, want)
+		}
+	}
+	for key := range doc {
+		if strings.Contains(key, ".") {
+			t.Errorf("document should not contain dotted key %q", key)
+		}
+	}
+}
+
+func TestExpandDottedKeysECS(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.DocumentFormat = elk.FormatECS
+	config.ExpandDottedKeys = true
+
+	entry := &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelWarn,
+		Message:   "slow",
+		Fields: elk.Fields{
+			"log.level":   "custom",
+			"log.file.id": "x",
+		},
+	}
+	doc := sendDocuments(t, config, entry)[0]
+
+	if got := lookup(doc, "log.level"); got != "warn" {
+		t.Errorf("log.level = %v, want warn", got)
+	}
+	if got := lookup(doc, "log.file.id"); got != "x" {
+		t.Errorf("log.file.id = %v, want x", got)
+	}
+	if got := lookup(doc, "custom_log.level"); got != "custom" {
+		t.Errorf("custom_log.level = %v, want custom", got)
+	}
+}
+
+func TestConfigFieldsValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*elk.Config)
+	}{
+		{"unknown collision policy", func(c *elk.Config) { c.FieldCollision = "merge" }},
+		{"reserved namespace", func(c *elk.Config) { c.FieldsNamespace = "message" }},
+		{"dotted namespace", func(c *elk.Config) { c.FieldsNamespace = "a.b" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := elk.DefaultConfig()
+			tt.modify(config)
+			if err := config.Validate(); err == nil {
+				t.Error("Validate should fail")
+			}
+		})
+	}
+}
+
+func TestLoadConfigFields(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+fields:
+  namespace: labels
+  collision: reject
+  collision_prefix: "user_"
+  expand_dotted_keys: true
+`)
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.FieldsNamespace != "labels" || config.FieldCollision != elk.CollisionReject ||
+		config.FieldCollisionPrefix != "user_" || !config.ExpandDottedKeys {
+		t.Errorf("fields config = %q %q %q %v", config.FieldsNamespace, config.FieldCollision,
+			config.FieldCollisionPrefix, config.ExpandDottedKeys)
+	}
+}
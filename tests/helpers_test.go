@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// newEntries 为每条消息创建一条info级别的日志
+func newEntries(messages ...string) []*elk.LogEntry {
+	entries := make([]*elk.LogEntry, len(messages))
+	for i, message := range messages {
+		entries[i] = elk.NewLogEntry(elk.LevelInfo, message, nil)
+	}
+	return entries
+}
+
+// newSender 创建发送器，测试结束时关闭
+func newSender(t *testing.T, config *elk.Config) *elk.Sender {
+	t.Helper()
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	t.Cleanup(func() { sender.Close() })
+	return sender
+}
+
+// sendDocuments 使用给定配置向假服务发送日志，返回写入的文档
+func sendDocuments(t *testing.T, config *elk.Config, entries ...*elk.LogEntry) []map[string]interface{} {
+	t.Helper()
+
+	server := elktest.NewServer(t)
+	config.ESAddresses = []string{server.URL}
+
+	if err := newSender(t, config).Send(context.Background(), entries); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	return sources(server)
+}
+
+// sources 返回假服务中写入成功的文档内容
+func sources(server *elktest.Server) []map[string]interface{} {
+	var docs []map[string]interface{}
+	for _, doc := range server.Documents() {
+		docs = append(docs, doc.Source)
+	}
+	return docs
+}
+
+// waitFor 轮询等待条件成立，超过5秒测试失败
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// recordingTransport 记录经过的请求，再交给默认传输层发送
+type recordingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.paths = append(t.paths, req.Method+" "+req.URL.Path)
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// requests 返回记录的请求，格式为 "方法 路径"
+func (t *recordingTransport) requests() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.paths...)
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestSizeLimitsTruncate(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxMessageBytes = 10
+	config.MaxStackBytes = 5
+	config.MaxFieldBytes = 8
+
+	entry := &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelError,
+		Message:   strings.Repeat("日志", 10), // 每个字符3字节
+		Stack:     strings.Repeat("s", 20),
+		Fields: elk.Fields{
+			"sql":   strings.Repeat("x", 100),
+			"short": "ok",
+			"count": 12345678901,
+		},
+		TypedFields: []elk.Field{elk.String("payload", strings.Repeat("p", 50))},
+	}
+
+	docs := sendDocuments(t, config, entry)
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+	doc := docs[0]
+
+	message := doc["message"].(string)
+	if !utf8.ValidString(message) || message != "日志日" {
+		t.Errorf("message = %q, want %q", message, "日志日")
+	}
+	if doc["stack"] != "sssss" {
+		t.Errorf("stack = %v, want 5 bytes", doc["stack"])
+	}
+	if doc["sql"] != "xxxxxxxx" || doc["payload"] != "pppppppp" {
+		t.Errorf("fields = %v %v, want 8 bytes", doc["sql"], doc["payload"])
+	}
+	if doc["short"] != "ok" || doc["count"] != float64(12345678901) {
+		t.Errorf("untouched fields = %v %v", doc["short"], doc["count"])
+	}
+
+	if doc["truncated"] != true {
+		t.Errorf("truncated = %v, want true", doc["truncated"])
+	}
+	original := len(entry.Message) + len(entry.Stack) + 100 + 2 + 50
+	if doc["original_size"] != float64(original) {
+		t.Errorf("original_size = %v, want %d", doc["original_size"], original)
+	}
+
+	// 原条目不被修改
+	if len(entry.Message) != 60 || entry.Truncated || len(entry.Fields["sql"].(string)) != 100 {
+		t.Error("limits modified the original entry")
+	}
+}
+
+func TestSizeLimitsWithinLimits(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxMessageBytes = 100
+	config.MaxFieldBytes = 100
+
+	doc := sendDocuments(t, config, &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelInfo,
+		Message:   "short",
+		Fields:    elk.Fields{"k": "v"},
+	})[0]
+	if _, ok := doc["truncated"]; ok {
+		t.Errorf("document = %v, want no truncation marker", doc)
+	}
+	if _, ok := doc["original_size"]; ok {
+		t.Errorf("document = %v, want no original_size", doc)
+	}
+}
+
+func TestSizeLimitsECS(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.DocumentFormat = elk.FormatECS
+	config.MaxMessageBytes = 4
+
+	doc := sendDocuments(t, config, &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelInfo,
+		Message:   "truncate me",
+	})[0]
+	if doc["message"] != "trun" {
+		t.Errorf("message = %v, want trun", doc["message"])
+	}
+	// 截断标记不是ECS字段，写入自定义命名空间
+	if lookup(doc, "elk_logger.truncated") != true || lookup(doc, "elk_logger.original_size") != float64(11) {
+		t.Errorf("elk_logger = %v, want truncation marker", doc["elk_logger"])
+	}
+	if lookup(doc, "log.truncated") != nil {
+		t.Errorf("log = %v, should only contain ECS fields", doc["log"])
+	}
+}
+
+func TestOversizeDocumentMarker(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxDocumentBytes = 400
+
+	doc := sendDocuments(t, config, &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelError,
+		Message:   "failed",
+		Stack:     strings.Repeat("stack line\n", 100),
+	})[0]
+	if doc["truncated"] != true || doc["original_size"] != float64(6+1100) {
+		t.Errorf("marker = %v %v, want true 1106", doc["truncated"], doc["original_size"])
+	}
+}
+
+func TestSizeLimitsMetrics(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+	config.MaxMessageBytes = 5
+	config.MaxDocumentBytes = 300
+	config.BatchSize = 10
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.Info("short")
+	_ = client.Info("a long message")
+	// 消息和文档都超限的日志只计数一次
+	_ = client.InfoTyped("a long message", elk.String("blob", strings.Repeat("b", 500)))
+	_ = client.Close()
+
+	if docs := server.Documents(); len(docs) != 2 {
+		t.Errorf("got %d documents, want 2", len(docs))
+	}
+	metrics := client.GetMetrics()
+	if metrics.TruncatedLogs != 1 || metrics.RejectedLogs != 1 {
+		t.Errorf("truncated = %d rejected = %d, want 1 and 1", metrics.TruncatedLogs, metrics.RejectedLogs)
+	}
+}
+
+func TestConfigSizeLimitsValidation(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxMessageBytes = -1
+	config.MaxStackBytes = -1
+	config.MaxFieldBytes = -1
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate should fail for negative limits")
+	}
+	for _, field := range []string{"max_message_bytes", "max_stack_bytes", "max_field_bytes"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error %q does not mention %s", err, field)
+		}
+	}
+}
+
+func TestLoadConfigSizeLimits(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+batch:
+  max_message_bytes: 1024
+  max_stack_bytes: 2048
+  max_field_bytes: 512
+`)
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.MaxMessageBytes != 1024 || config.MaxStackBytes != 2048 || config.MaxFieldBytes != 512 {
+		t.Errorf("limits = %d %d %d", config.MaxMessageBytes, config.MaxStackBytes, config.MaxFieldBytes)
+	}
+}
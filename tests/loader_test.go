@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+)
+
+// writeConfigFile 写入临时配置文件
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadExampleConfig(t *testing.T) {
+	config, err := elk.LoadConfig("../config.example.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.ServiceName != "my-application" {
+		t.Errorf("ServiceName = %s, want my-application", config.ServiceName)
+	}
+	if config.BatchTimeout != 5*time.Second {
+		t.Errorf("BatchTimeout = %v, want 5s", config.BatchTimeout)
+	}
+	if config.TargetLatency != 500*time.Millisecond {
+		t.Errorf("TargetLatency = %v, want 500ms", config.TargetLatency)
+	}
+	if config.QueueFullPolicy != elk.PolicyBlockTimeout {
+		t.Errorf("QueueFullPolicy = %s, want block_timeout", config.QueueFullPolicy)
+	}
+}
+
+func TestLoadConfigProfileAndDurations(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+elasticsearch:
+  addresses:
+    - http://localhost:9200
+batch:
+  size: 50
+  timeout: "2s"
+application:
+  service_name: base
+  environment: staging
+production:
+  elasticsearch:
+    addresses:
+      - http://es-prod-1:9200
+      - http://es-prod-2:9200
+  batch:
+    size: 500
+    flush_interval: 500ms
+staging:
+  application:
+    service_name: staging-service
+`)
+
+	// 未指定时使用application.environment对应的配置段
+	config, err := elk.LoadConfigProfile(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfigProfile failed: %v", err)
+	}
+	if config.ServiceName != "staging-service" {
+		t.Errorf("ServiceName = %s, want staging-service", config.ServiceName)
+	}
+	if config.BatchTimeout != 2*time.Second {
+		t.Errorf("BatchTimeout = %v, want 2s", config.BatchTimeout)
+	}
+
+	config, err = elk.LoadConfigProfile(path, "production")
+	if err != nil {
+		t.Fatalf("LoadConfigProfile failed: %v", err)
+	}
+	if config.BatchSize != 500 {
+		t.Errorf("BatchSize = %d, want 500", config.BatchSize)
+	}
+	if len(config.ESAddresses) != 2 {
+		t.Errorf("ESAddresses = %v, want 2 addresses", config.ESAddresses)
+	}
+	if config.FlushInterval != 500*time.Millisecond {
+		t.Errorf("FlushInterval = %v, want 500ms", config.FlushInterval)
+	}
+	if config.ServiceName != "base" {
+		t.Errorf("ServiceName = %s, want base", config.ServiceName)
+	}
+}
+
+func TestLoadConfigEnvOverride(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+  "elasticsearch": {"addresses": ["http://localhost:9200"]},
+  "batch": {"size": 50, "timeout": 5}
+}`)
+
+	t.Setenv("ELK_BATCH_SIZE", "200")
+	t.Setenv("ELK_BATCH_TIMEOUT", "1m")
+	t.Setenv("ELK_ELASTICSEARCH_ADDRESSES", "http://es1:9200, http://es2:9200")
+	t.Setenv("ELK_APPLICATION_ENABLE_HOST_INFO", "false")
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.BatchSize != 200 {
+		t.Errorf("BatchSize = %d, want 200", config.BatchSize)
+	}
+	if config.BatchTimeout != time.Minute {
+		t.Errorf("BatchTimeout = %v, want 1m", config.BatchTimeout)
+	}
+	if len(config.ESAddresses) != 2 || config.ESAddresses[1] != "http://es2:9200" {
+		t.Errorf("ESAddresses = %v, want [http://es1:9200 http://es2:9200]", config.ESAddresses)
+	}
+	if config.EnableHostInfo {
+		t.Error("EnableHostInfo should be overridden to false")
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		wantKey string
+	}{
+		{
+			name:    "unknown key",
+			content: "batch:\n  sise: 10\n",
+			wantKey: "batch.sise",
+		},
+		{
+			name:    "invalid duration",
+			content: "batch:\n  timeout: 5x\n",
+			wantKey: "batch.timeout",
+		},
+		{
+			name:    "invalid value",
+			content: "queue:\n  worker_count: 0\n",
+			wantKey: "queue.worker_count",
+		},
+		{
+			name:    "typo in profile",
+			content: "production:\n  batch:\n    sise: 10\n",
+			wantKey: "batch.sise",
+		},
+		{
+			name:    "invalid env value",
+			content: "batch:\n  size: 10\n",
+			env:     map[string]string{"ELK_BATCH_SIZE": "many"},
+			wantKey: "ELK_BATCH_SIZE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := writeConfigFile(t, "config.yaml", tt.content)
+
+			_, err := elk.LoadConfig(path)
+			if err == nil {
+				t.Fatal("expected error but got nil")
+			}
+
+			var keyErr *elk.ConfigKeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("expected ConfigKeyError, got %v", err)
+			}
+			if keyErr.Key != tt.wantKey {
+				t.Errorf("error key = %s, want %s (%v)", keyErr.Key, tt.wantKey, err)
+			}
+		})
+	}
+}
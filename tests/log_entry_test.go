@@ -148,3 +148,23 @@ func TestLogEntryWithNilFields(t *testing.T) {
 		t.Error("message field missing or incorrect")
 	}
 }
+
+func TestLogLevelPriority(t *testing.T) {
+	levels := []elk.LogLevel{
+		elk.LevelDebug,
+		elk.LevelInfo,
+		elk.LevelWarn,
+		elk.LevelError,
+		elk.LevelFatal,
+	}
+
+	for i := 1; i < len(levels); i++ {
+		if levels[i].Priority() <= levels[i-1].Priority() {
+			t.Errorf("%s priority should be higher than %s", levels[i], levels[i-1])
+		}
+	}
+
+	if elk.LogLevel("unknown").Priority() != elk.LevelInfo.Priority() {
+		t.Error("unknown level should be treated as info")
+	}
+}
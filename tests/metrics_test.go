@@ -5,6 +5,7 @@ import (
 	"time"
 
 	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
 )
 
 func TestMetricsIncrement(t *testing.T) {
@@ -126,3 +127,34 @@ func TestMetricsSnapshot(t *testing.T) {
 		t.Error("Snapshot should reflect new metrics")
 	}
 }
+
+func TestClientMetricsCountLogs(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{1: elktest.ItemMappingError}})
+
+	config := server.NewConfig()
+	config.BatchSize = 3
+	config.BatchTimeout = time.Minute
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, message := range []string{"a", "b", "c"} {
+		if err := client.Info(message); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 一个批次中的三条日志按条计数，不按批次计数
+	metrics := client.GetMetrics()
+	if metrics.SuccessLogs != 2 || metrics.FailedLogs != 1 {
+		t.Errorf("success/failed = %d/%d, want 2/1", metrics.SuccessLogs, metrics.FailedLogs)
+	}
+	if n := server.BulkRequests(); n != 1 {
+		t.Errorf("bulk requests = %d, want 1", n)
+	}
+}
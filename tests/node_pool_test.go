@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// nodeTestConfig 返回把多个假服务作为同一集群节点的配置
+func nodeTestConfig(nodes ...*elktest.Server) *elk.Config {
+	config := nodes[0].NewConfig()
+	config.ESAddresses = nil
+	for _, node := range nodes {
+		config.ESAddresses = append(config.ESAddresses, node.URL)
+	}
+	config.MaxRetryBackoff = time.Millisecond
+	return config
+}
+
+func TestSenderMarksFailingNodeDead(t *testing.T) {
+	bad, good := elktest.NewServer(t), elktest.NewServer(t)
+	bad.SetDefault(elktest.Response{Status: http.StatusServiceUnavailable})
+
+	sender, err := elk.NewSender(nodeTestConfig(bad, good))
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := sender.SendWithRetry(context.Background(), newEntries("hello")); err != nil {
+			t.Fatalf("SendWithRetry failed: %v", err)
+		}
+	}
+
+	if n := bad.BulkRequests(); n != 1 {
+		t.Errorf("failing node received %d bulk requests, want 1 before being marked dead", n)
+	}
+	if n := good.BulkRequests(); n != 10 {
+		t.Errorf("healthy node received %d bulk requests, want 10", n)
+	}
+}
+
+func TestSenderResurrectsDeadNode(t *testing.T) {
+	flaky, good := elktest.NewServer(t), elktest.NewServer(t)
+	flaky.SetDefault(elktest.Response{Status: http.StatusBadGateway})
+
+	config := nodeTestConfig(flaky, good)
+	config.NodeResurrectTimeout = 50 * time.Millisecond
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := sender.SendWithRetry(context.Background(), newEntries("hello")); err != nil {
+			t.Fatalf("SendWithRetry failed: %v", err)
+		}
+	}
+
+	// 节点恢复后，等待时间到期即重新参与负载
+	flaky.SetDefault(elktest.Response{})
+	time.Sleep(100 * time.Millisecond)
+	before := flaky.BulkRequests()
+	for i := 0; i < 4; i++ {
+		if err := sender.SendWithRetry(context.Background(), newEntries("hello")); err != nil {
+			t.Fatalf("SendWithRetry failed: %v", err)
+		}
+	}
+	if flaky.BulkRequests() == before {
+		t.Error("resurrected node should receive requests again")
+	}
+}
+
+func TestSenderSniffingPrefersCoordinatingNodes(t *testing.T) {
+	seed, data, coordinating, ingest := elktest.NewServer(t), elktest.NewServer(t), elktest.NewServer(t), elktest.NewServer(t)
+	seed.SetNodes(
+		elktest.Node{Name: "data", Roles: []string{"data", "master"}, Address: data.Host()},
+		elktest.Node{Name: "coord", Address: coordinating.Host()},
+		elktest.Node{Name: "ingest", Roles: []string{"data", "ingest"}, Address: ingest.Host()},
+	)
+
+	config := nodeTestConfig(seed)
+	config.EnableSniffing = true
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	// 启动时异步嗅探，等待请求切换到仅协调节点
+	waitFor(t, "sniffing", func() bool {
+		_ = sender.SendWithRetry(context.Background(), newEntries("hello"))
+		return coordinating.BulkRequests() > 0
+	})
+
+	before := coordinating.BulkRequests()
+	for i := 0; i < 5; i++ {
+		if err := sender.SendWithRetry(context.Background(), newEntries("hello")); err != nil {
+			t.Fatalf("SendWithRetry failed: %v", err)
+		}
+	}
+	if got := coordinating.BulkRequests() - before; got != 5 {
+		t.Errorf("coordinating node received %d of 5 bulk requests", got)
+	}
+	if data.BulkRequests() != 0 {
+		t.Error("node without ingest role should not receive bulk requests")
+	}
+}
+
+func TestClientNodeMetrics(t *testing.T) {
+	bad, good := elktest.NewServer(t), elktest.NewServer(t)
+	bad.SetDefault(elktest.Response{Status: http.StatusServiceUnavailable})
+
+	config := nodeTestConfig(bad, good)
+	config.BatchSize = 1
+	config.FallbackHandler = func([]*elk.LogEntry, error) {}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 4; i++ {
+		if err := client.Info("hello", nil); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	waitFor(t, "logs to be sent", func() bool {
+		return client.GetMetrics().SuccessLogs == 4
+	})
+
+	nodes := client.GetMetrics().Nodes
+	// 多个发送协程可能在节点被标记前同时请求它
+	if stats := nodes[bad.Host()]; !stats.Dead || stats.Failures == 0 || stats.Failures != stats.Requests {
+		t.Errorf("failing node stats = %+v, want dead with only failed requests", stats)
+	}
+	if stats := nodes[good.Host()]; stats.Dead || stats.Requests < 4 || stats.Failures != 0 {
+		t.Errorf("healthy node stats = %+v, want at least 4 requests without failures", stats)
+	}
+}
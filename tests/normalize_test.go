@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+type normalizeBase struct {
+	ID      int    `json:"id"`
+	Ignored string `json:"-"`
+}
+
+type normalizeUser struct {
+	normalizeBase
+	Name     string         `json:"name"`
+	Email    string         `json:"email,omitempty"`
+	Age      int            `json:"age,string"`
+	Tags     []string       `json:"tags"`
+	Attrs    map[int]string `json:"attrs"`
+	LastErr  error          `json:"last_err"`
+	Addr     net.IP         `json:"addr"`
+	Callback func()         `json:"-"`
+	internal string
+	Extra    map[string]string `json:",omitempty"`
+}
+
+type normalizeNode struct {
+	Name string         `json:"name"`
+	Next *normalizeNode `json:"next"`
+}
+
+type normalizeLevel int
+
+func (l normalizeLevel) String() string { return "level" }
+
+type normalizeVersion struct{ Major, Minor int }
+
+func (v normalizeVersion) String() string { return "v1.2" }
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) { return nil, errors.New("marshal failed") }
+
+type panickingStringer struct{ p *int }
+
+func (s panickingStringer) String() string { return string(rune(*s.p)) }
+
+func TestNormalizeStruct(t *testing.T) {
+	user := normalizeUser{
+		normalizeBase: normalizeBase{ID: 7, Ignored: "x"},
+		Name:          "alice",
+		Age:           30,
+		Tags:          []string{"a", "b"},
+		Attrs:         map[int]string{1: "one"},
+		LastErr:       errors.New("timeout"),
+		Addr:          net.ParseIP("10.0.0.1"),
+		Callback:      func() {},
+		internal:      "hidden",
+	}
+
+	entry := elk.NewLogEntry(elk.LevelInfo, "user", elk.Fields{"user": user})
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	doc := decode(t, data)
+
+	expected := map[string]interface{}{
+		"user.id":       float64(7),
+		"user.name":     "alice",
+		"user.age":      "30",
+		"user.tags":     nil, // 切片单独检查
+		"user.attrs.1":  "one",
+		"user.last_err": "timeout",
+		"user.addr":     "10.0.0.1",
+	}
+	for path, want := range expected {
+		if path == "user.tags" {
+			continue
+		}
+		if got := lookup(doc, path); got != want {
+			t.Errorf("%s = %#v, want %#v", path, got, want)
+		}
+	}
+	u := doc["user"].(map[string]interface{})
+	if tags, ok := u["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("tags = %v, want 2 elements", u["tags"])
+	}
+	for _, key := range []string{"Ignored", "email", "Callback", "internal", "Extra"} {
+		if _, ok := u[key]; ok {
+			t.Errorf("user has %s, want it omitted", key)
+		}
+	}
+}
+
+func TestNormalizeMethods(t *testing.T) {
+	entry := elk.NewLogEntry(elk.LevelInfo, "methods", elk.Fields{
+		"err":     errors.New("boom"),
+		"level":   normalizeLevel(3),
+		"version": normalizeVersion{1, 2},
+		"number":  json.Number("12.5"),
+		"at":      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	doc := decode(t, data)
+
+	// 与内置字段level同名，加上前缀
+	expected := map[string]interface{}{
+		"err":          "boom",
+		"custom_level": float64(3),
+		"version":      "v1.2",
+		"number":       12.5,
+		"at":           "2024-01-02T03:04:05Z",
+	}
+	for key, want := range expected {
+		if got := doc[key]; got != want {
+			t.Errorf("%s = %#v, want %#v", key, got, want)
+		}
+	}
+}
+
+func TestNormalizeCycles(t *testing.T) {
+	node := &normalizeNode{Name: "a"}
+	node.Next = node
+
+	m := map[string]interface{}{"name": "m"}
+	m["self"] = m
+
+	s := []interface{}{"s", nil}
+	s[1] = s
+
+	entry := elk.NewLogEntry(elk.LevelInfo, "cycles", elk.Fields{"node": node, "map": m, "slice": s})
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	doc := decode(t, data)
+
+	if lookup(doc, "node.name") != "a" || lookup(doc, "node.next") != nil {
+		t.Errorf("node = %v, want the cycle replaced by null", doc["node"])
+	}
+	if lookup(doc, "map.name") != "m" || lookup(doc, "map.self") != nil {
+		t.Errorf("map = %v, want the cycle replaced by null", doc["map"])
+	}
+	if slice := doc["slice"].([]interface{}); len(slice) != 2 || slice[0] != "s" || slice[1] != nil {
+		t.Errorf("slice = %v, want the cycle replaced by null", slice)
+	}
+
+	// 同一个对象出现多次不是循环引用
+	shared := &normalizeNode{Name: "shared"}
+	entry = elk.NewLogEntry(elk.LevelInfo, "shared", elk.Fields{"pair": []*normalizeNode{shared, shared}})
+	data, err = entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	for i, v := range decode(t, data)["pair"].([]interface{}) {
+		if v == nil {
+			t.Errorf("pair[%d] = null, want the shared node", i)
+		}
+	}
+}
+
+func TestNormalizeMaxDepth(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxFieldDepth = 2
+
+	nested := map[string]interface{}{
+		"l1": map[string]interface{}{
+			"l2": map[string]interface{}{"l3": "deep"},
+			"v":  "kept",
+		},
+	}
+	doc := sendDocuments(t, config, elk.NewLogEntry(elk.LevelInfo, "deep", elk.Fields{"nested": nested}))[0]
+
+	if lookup(doc, "nested.l1.v") != "kept" {
+		t.Errorf("nested = %v, want l1.v kept", doc["nested"])
+	}
+	if l1 := doc["nested"].(map[string]interface{})["l1"].(map[string]interface{}); l1["l2"] != nil {
+		t.Errorf("l2 = %v, want null beyond max depth", l1["l2"])
+	}
+}
+
+func TestSenderIsolatesEncodingErrors(t *testing.T) {
+	entries := []*elk.LogEntry{
+		elk.NewLogEntry(elk.LevelInfo, "before", elk.Fields{"ok": 1}),
+		elk.NewLogEntry(elk.LevelError, "channel", elk.Fields{"ch": make(chan int), "ok": 2}),
+		elk.NewLogEntry(elk.LevelError, "marshaler", elk.Fields{"bad": failingMarshaler{}}),
+		elk.NewLogEntry(elk.LevelError, "panic", elk.Fields{"bad": panickingStringer{}}),
+		{Timestamp: time.Now(), Level: elk.LevelInfo, Message: "typed", TypedFields: []elk.Field{elk.Any("fn", func() {})}},
+		elk.NewLogEntry(elk.LevelInfo, "after", elk.Fields{"ok": 3}),
+	}
+
+	docs := sendDocuments(t, elk.DefaultConfig(), entries...)
+	if len(docs) != len(entries) {
+		t.Fatalf("got %d documents, want %d", len(docs), len(entries))
+	}
+
+	for i, doc := range docs {
+		if doc["message"] != entries[i].Message {
+			t.Errorf("doc %d message = %v, want %s", i, doc["message"], entries[i].Message)
+		}
+		bad := i > 0 && i < len(docs)-1
+		encErr, _ := doc["encoding_error"].(string)
+		if bad != (encErr != "") {
+			t.Errorf("doc %d encoding_error = %q", i, encErr)
+		}
+		if bad && doc["ok"] != nil {
+			t.Errorf("doc %d kept custom fields after encoding error: %v", i, doc)
+		}
+	}
+	if !strings.Contains(docs[1]["encoding_error"].(string), "chan int") {
+		t.Errorf("encoding_error = %v, want it to mention the type", docs[1]["encoding_error"])
+	}
+}
+
+func TestClientEncodingErrorMetrics(t *testing.T) {
+	server := elktest.NewServer(t)
+	client, err := elk.NewClient(server.NewConfig())
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	_ = client.InfoTyped("bad", elk.Any("fn", func() {}))
+	_ = client.InfoTyped("good", elk.Int("n", 1))
+	_ = client.Close()
+
+	if docs := server.Documents(); len(docs) != 2 {
+		t.Errorf("got %d documents, want 2", len(docs))
+	}
+	metrics := client.GetMetrics()
+	if metrics.EncodingErrors != 1 || metrics.FailedLogs != 0 {
+		t.Errorf("encoding errors = %d failed = %d, want 1 and 0", metrics.EncodingErrors, metrics.FailedLogs)
+	}
+}
+
+func TestConfigMaxFieldDepth(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.MaxFieldDepth = -1
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "max_field_depth") {
+		t.Errorf("Validate = %v, want max_field_depth error", err)
+	}
+
+	path := writeConfigFile(t, "config.yaml", `
+fields:
+  max_depth: 4
+`)
+	loaded, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if loaded.MaxFieldDepth != 4 {
+		t.Errorf("MaxFieldDepth = %d, want 4", loaded.MaxFieldDepth)
+	}
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestClientRecyclesEntriesAfterFallback(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Status: http.StatusBadRequest})
+
+	config := server.NewConfig()
+	config.RetryCount = 0
+
+	var mu sync.Mutex
+	var received, kept []*elk.LogEntry
+	config.FallbackHandler = func(entries []*elk.LogEntry, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			if entry.Message != "spooled" {
+				t.Errorf("fallback entry message = %q, want spooled", entry.Message)
+			}
+			received = append(received, entry)
+			kept = append(kept, entry.Clone())
+		}
+	}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := client.Info("spooled", elk.Fields{"i": i}); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	_ = client.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(kept) != 3 {
+		t.Fatalf("fallback received %d entries, want 3", len(kept))
+	}
+	// FallbackHandler返回后条目被回收，需要保留的数据应使用Clone
+	for i, entry := range kept {
+		if entry.Message != "spooled" || entry.Fields["i"] == nil {
+			t.Errorf("cloned entry %d = %+v, want original content", i, entry)
+		}
+		if received[i].Message != "" {
+			t.Errorf("entry %d should be recycled after the fallback handler returns", i)
+		}
+	}
+}
+
+func TestClientRecyclesDroppedEntries(t *testing.T) {
+	config := elktest.NewServer(t).NewConfig()
+	config.QueueFullPolicy = elk.PolicyDropNewest
+	config.QueueSize = 1
+	config.BatchSize = 1
+	config.WorkerCount = 1
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 1000; i++ {
+		if err := client.Info("burst", nil); err != nil && !errors.Is(err, elk.ErrQueueFull) {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	client.Flush()
+
+	// 丢弃的条目同样被回收，不影响后续日志
+	if err := client.Error("after burst", nil); err != nil {
+		t.Fatalf("Error failed: %v", err)
+	}
+	if got := client.GetMetrics().TotalLogs; got != 1001 {
+		t.Errorf("TotalLogs = %d, want 1001", got)
+	}
+}
+
+// discardTransport 丢弃请求体并返回固定的成功响应，不解析请求
+// 基准测试只测量客户端入队、批量和编码的开销
+type discardTransport struct{}
+
+func (discardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Elastic-Product", "Elasticsearch")
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"took":0,"errors":false,"items":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+// BenchmarkClientLog 持续写入日志，条目和批次切片在发送后回收
+func BenchmarkClientLog(b *testing.B) {
+	config := elk.DefaultConfig()
+	config.ESAddresses = []string{"http://discard:9200"}
+	config.Transport = discardTransport{}
+	config.EnableHostInfo = false
+	config.QueueFullPolicy = elk.PolicyBlock
+	// gzip压缩的分配远多于条目和缓冲区，关闭后池化的效果才能体现在结果中
+	config.EnableCompression = false
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	fields := elk.Fields{"user_id": 12345, "order_id": "ORD-1"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.Info("order created", fields); err != nil {
+			b.Fatal(err)
+		}
+	}
+	_ = client.Close()
+	b.StopTimer()
+
+	if n := client.GetMetrics().SuccessLogs; n != int64(b.N) {
+		b.Fatalf("success logs = %d, want %d", n, b.N)
+	}
+}
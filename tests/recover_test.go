@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// explode 记录panic所在的位置后触发panic
+func explode(at *string) {
+	_, file, line, _ := runtime.Caller(0)
+	*at = fmt.Sprintf("%s:%d", file, line+2)
+	panic("boom")
+}
+
+func newRecoverClient(t *testing.T, server *elktest.Server, repanic bool) *elk.Client {
+	t.Helper()
+
+	config := server.NewConfig()
+	config.BatchTimeout = time.Minute
+	config.RepanicAfterRecover = repanic
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRecoverAndLogRecordsStackAndCaller(t *testing.T) {
+	server := elktest.NewServer(t)
+	client := newRecoverClient(t, server, false)
+
+	var at string
+	func() {
+		defer client.RecoverAndLog(elk.Fields{"request_id": "r-1"})
+		explode(&at)
+	}()
+
+	// panic日志同步发送，RecoverAndLog返回时已经写入
+	docs := server.Documents()
+	if len(docs) != 1 {
+		t.Fatalf("stored %d documents, want the panic log", len(docs))
+	}
+	source := docs[0].Source
+	if source["message"] != "panic: boom" || source["level"] != string(elk.LevelFatal) {
+		t.Errorf("document = %v, want a fatal panic log", source)
+	}
+	if source["caller"] != at {
+		t.Errorf("caller = %v, want the panic site %s", source["caller"], at)
+	}
+	if stack, _ := source["stack"].(string); !strings.Contains(stack, "tests.explode") {
+		t.Errorf("stack should contain the panicking function:\n%s", stack)
+	}
+	if !strings.Contains(string(docs[0].Raw), `"request_id":"r-1"`) {
+		t.Errorf("document should carry the caller's fields: %s", docs[0].Raw)
+	}
+}
+
+func TestRecoverAndLogRepanics(t *testing.T) {
+	server := elktest.NewServer(t)
+	client := newRecoverClient(t, server, true)
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		defer client.RecoverAndLog(nil)
+		var at string
+		explode(&at)
+	}()
+
+	if recovered != "boom" {
+		t.Errorf("recovered = %v, want the original panic value re-raised", recovered)
+	}
+	if messages := server.Messages(); len(messages) != 1 || messages[0] != "panic: boom" {
+		t.Errorf("messages = %v, want the panic logged before re-panicking", messages)
+	}
+}
+
+func TestClientGoLogsPanics(t *testing.T) {
+	server := elktest.NewServer(t)
+	client := newRecoverClient(t, server, false)
+
+	var at string
+	done := make(chan struct{})
+	client.Go(func() {
+		defer close(done)
+		explode(&at)
+	})
+	<-done
+
+	if !server.WaitForDocuments(1, 5*time.Second) {
+		t.Fatal("panic in goroutine was not logged")
+	}
+	if source := server.Documents()[0].Source; source["caller"] != at {
+		t.Errorf("caller = %v, want %s", source["caller"], at)
+	}
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestUpdateConfigAppliesSafeChanges(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	next := *config
+	next.IndexPattern = "app-{date}"
+	next.MinLevel = elk.LevelWarn
+	if err := client.UpdateConfig(&next); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+
+	_ = client.Info("filtered")
+	_ = client.Warn("kept")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	docs := server.Documents()
+	if len(docs) != 1 || docs[0].Source["message"] != "kept" {
+		t.Fatalf("documents = %v, want only the warn entry", server.Messages())
+	}
+	if !strings.HasPrefix(docs[0].Index, "app-") {
+		t.Errorf("index = %s, want the updated index pattern", docs[0].Index)
+	}
+}
+
+func TestUpdateConfigRejectsUnsafeChanges(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	next := *config
+	next.QueueSize *= 2
+	next.IndexPattern = "app-{date}"
+	err = client.UpdateConfig(&next)
+	if !errors.Is(err, elk.ErrRestartRequired) || !strings.Contains(err.Error(), "queue_size") {
+		t.Fatalf("err = %v, want ErrRestartRequired naming queue_size", err)
+	}
+
+	// 拒绝时不应用任何修改，包括其中可以热更新的配置
+	_ = client.Info("unchanged")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	docs := server.Documents()
+	if len(docs) != 1 || !strings.HasPrefix(docs[0].Index, "logs-") {
+		t.Errorf("documents = %+v, want one entry in the original index", docs)
+	}
+}
+
+func TestUpdateConfigKeepsQueuedEntriesOnSenderRebuild(t *testing.T) {
+	old, replacement := elktest.NewServer(t), elktest.NewServer(t)
+
+	config := old.NewConfig()
+	config.BatchSize = 100
+	config.BatchTimeout = time.Minute
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	for _, message := range []string{"first", "second", "third"} {
+		_ = client.Info(message)
+	}
+
+	// 地址变化时重建发送器，已入队和已在批次中的日志由新发送器发送
+	next := *config
+	next.ESAddresses = []string{replacement.URL}
+	if err := client.UpdateConfig(&next); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if n := old.BulkRequests(); n != 0 {
+		t.Errorf("old cluster received %d bulk requests, want 0", n)
+	}
+	if messages := replacement.Messages(); !slices.Equal(messages, []string{"first", "second", "third"}) {
+		t.Errorf("new cluster stored %v, want all queued entries", messages)
+	}
+}
+
+// writeWatchedConfig 写入包含地址和索引模式的配置文件，extra为追加的配置段
+// 文件系统的修改时间精度可能较粗，修改时间由调用方显式指定
+func writeWatchedConfig(t *testing.T, path string, server *elktest.Server, indexPattern, extra string, modTime time.Time) {
+	t.Helper()
+	data := fmt.Sprintf("elasticsearch:\n  addresses: [%q]\n  index_pattern: %q\n%s", server.URL, indexPattern, extra)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+// watchAndWait 监视配置文件，调用change修改文件后等待重新加载完成
+func watchAndWait(t *testing.T, client *elk.Client, path string, change func()) {
+	t.Helper()
+
+	reloaded := make(chan error, 1)
+	onReload := func(err error) {
+		select {
+		case reloaded <- err:
+		default:
+		}
+	}
+	if err := client.WatchConfig(path, 10*time.Millisecond, onReload); err != nil {
+		t.Fatalf("WatchConfig failed: %v", err)
+	}
+	change()
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("reload failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change was not picked up")
+	}
+}
+
+func TestWatchConfigReloadsChangedFile(t *testing.T) {
+	server := elktest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "elk.yaml")
+	writeWatchedConfig(t, path, server, "logs-{date}", "", time.Now())
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	watchAndWait(t, client, path, func() {
+		writeWatchedConfig(t, path, server, "watched-{date}", "", time.Now().Add(time.Second))
+	})
+
+	_ = client.Info("after reload")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(server.Documents()) != 1 {
+		t.Fatalf("documents = %v, want the log written after reload", server.Messages())
+	}
+	if index := server.Documents()[0].Index; !strings.HasPrefix(index, "watched-") {
+		t.Errorf("index = %s, want the reloaded index pattern", index)
+	}
+}
+
+func TestWatchConfigKeepsCodeOnlySettings(t *testing.T) {
+	server := elktest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "elk.yaml")
+	debugOn := "advanced:\n  enable_debug: true\n"
+	writeWatchedConfig(t, path, server, "logs-{date}", debugOn, time.Now())
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	transport := &recordingTransport{}
+	var debug bytes.Buffer
+	var fallbackCalls atomic.Int64
+	config.Transport = transport
+	config.DebugWriter = &debug
+	config.FallbackHandler = func([]*elk.LogEntry, error) { fallbackCalls.Add(1) }
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	watchAndWait(t, client, path, func() {
+		writeWatchedConfig(t, path, server, "watched-{date}", debugOn, time.Now().Add(time.Second))
+	})
+
+	server.SetDefault(elktest.Response{Status: http.StatusBadRequest})
+	_ = client.Info("after reload")
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 自定义传输层、调试输出和回调在重新加载后继续生效
+	bulks := 0
+	for _, request := range transport.requests() {
+		if strings.HasSuffix(request, "/_bulk") {
+			bulks++
+		}
+	}
+	if bulks != 1 {
+		t.Errorf("custom transport saw %d bulk requests, want 1", bulks)
+	}
+	if !strings.Contains(debug.String(), "_bulk") {
+		t.Errorf("debug writer should receive the request log after reload, got:\n%s", debug.String())
+	}
+	if n := fallbackCalls.Load(); n != 1 {
+		t.Errorf("fallback calls = %d, want 1", n)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestSendWithRetryRetriesUnavailable(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(
+		elktest.Response{Status: http.StatusServiceUnavailable},
+		elktest.Response{Status: http.StatusBadGateway},
+	)
+	sender := newSender(t, server.NewConfig())
+
+	if err := sender.SendWithRetry(context.Background(), newEntries("first", "second")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+	if n := server.BulkRequests(); n != 3 {
+		t.Errorf("bulk requests = %d, want 3", n)
+	}
+}
+
+func TestSendWithRetryStopsOnPermanentError(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Status: http.StatusBadRequest})
+	sender := newSender(t, server.NewConfig())
+
+	err := sender.SendWithRetry(context.Background(), newEntries("first", "second"))
+	var bulkErr *elk.BulkError
+	if !errors.As(err, &bulkErr) || bulkErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want 400 BulkError", err)
+	}
+	if n := server.BulkRequests(); n != 1 {
+		t.Errorf("bulk requests = %d, want 1 (400 is not retryable)", n)
+	}
+}
+
+func TestSendWithRetryGivesUp(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Status: http.StatusTooManyRequests})
+	sender := newSender(t, server.NewConfig())
+
+	err := sender.SendWithRetry(context.Background(), newEntries("first", "second"))
+	if !elk.IsRejected(err) {
+		t.Fatalf("err = %v, want rejected error", err)
+	}
+	if n := server.BulkRequests(); n != 4 {
+		t.Errorf("bulk requests = %d, want 1 + 3 retries", n)
+	}
+}
+
+func TestSendWithRetryResendsOnlyRejectedItems(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{ItemErrors: map[int]elktest.ItemError{1: elktest.ItemRejected}})
+	sender := newSender(t, server.NewConfig())
+
+	if err := sender.SendWithRetry(context.Background(), newEntries("first", "second")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+
+	if n := server.BulkRequests(); n != 2 {
+		t.Fatalf("bulk requests = %d, want 2", n)
+	}
+	// 已写入的条目不会重复写入
+	if messages := server.Messages(); !slices.Equal(messages, []string{"first", "second"}) {
+		t.Errorf("messages = %v, want each entry stored once", messages)
+	}
+}
+
+func TestSendWithRetryHonoursRetryAfter(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Status: http.StatusTooManyRequests, RetryAfter: "1"})
+	sender := newSender(t, server.NewConfig())
+
+	start := time.Now()
+	if err := sender.SendWithRetry(context.Background(), newEntries("first", "second")); err != nil {
+		t.Fatalf("SendWithRetry failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least Retry-After 1s", elapsed)
+	}
+}
+
+func TestSendWithRetryRespectsDeadline(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.Enqueue(elktest.Response{Status: http.StatusTooManyRequests, RetryAfter: "60"})
+	sender := newSender(t, server.NewConfig())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := sender.SendWithRetry(ctx, newEntries("first", "second")); err == nil {
+		t.Fatal("expected error when Retry-After exceeds the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, should not wait past the deadline", elapsed)
+	}
+	if n := server.BulkRequests(); n != 1 {
+		t.Errorf("bulk requests = %d, want 1", n)
+	}
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestClientSenderPoolBoundsConcurrentBulks(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Latency: 300 * time.Millisecond})
+
+	config := server.NewConfig()
+	config.BatchSize = 1
+	config.SenderCount = 2
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	// 发送协程繁忙时日志仍然只进入队列，不阻塞调用方
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := client.InfoTyped("pooled", elk.Int("i", i)); err != nil {
+			t.Fatalf("InfoTyped failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("logging took %v, should not wait for bulk requests", elapsed)
+	}
+
+	// 同时进行的批量请求不超过SenderCount，其余批次等待提交
+	waitFor(t, "bulk requests to start", func() bool {
+		return server.BulkRequests() == config.SenderCount
+	})
+	time.Sleep(100 * time.Millisecond)
+	if n := server.BulkRequests(); n != config.SenderCount {
+		t.Errorf("concurrent bulk requests = %d, want SenderCount %d", n, config.SenderCount)
+	}
+	if n := client.GetMetrics().PendingBatches; n < int64(config.SenderCount) {
+		t.Errorf("pending batches = %d, want at least %d", n, config.SenderCount)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if n := len(server.Documents()); n != 6 {
+		t.Errorf("stored %d documents, want 6", n)
+	}
+}
+
+func TestClientFlushWaitsForPendingBatches(t *testing.T) {
+	server := elktest.NewServer(t)
+	server.SetDefault(elktest.Response{Latency: 200 * time.Millisecond})
+
+	config := server.NewConfig()
+	config.BatchSize = 1
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Info("flushed"); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	waitFor(t, "bulk request to start", func() bool {
+		return server.BulkRequests() == 1
+	})
+
+	// Flush返回时已提交的批次已经发送完成
+	client.Flush()
+	if messages := server.Messages(); len(messages) != 1 || messages[0] != "flushed" {
+		t.Errorf("messages after Flush = %v, want [flushed]", messages)
+	}
+	if n := client.GetMetrics().PendingBatches; n != 0 {
+		t.Errorf("pending batches after Flush = %d, want 0", n)
+	}
+}
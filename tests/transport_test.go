@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestSenderCustomTransport(t *testing.T) {
+	server := elktest.NewServer(t)
+	transport := &recordingTransport{}
+
+	config := server.NewConfig()
+	config.Transport = transport
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	if err := sender.Send(context.Background(), newEntries("hello")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	requests := transport.requests()
+	if len(requests) != 2 || requests[0] != "HEAD /" || requests[1] != "POST /_bulk" {
+		t.Errorf("requests = %v, want ping and bulk", requests)
+	}
+}
+
+func TestSenderDebugLog(t *testing.T) {
+	var debug bytes.Buffer
+
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+	config.EnableCompression = false
+	config.EnableDebug = true
+	config.DebugWriter = &debug
+
+	sender, err := elk.NewSender(config)
+	if err != nil {
+		t.Fatalf("NewSender failed: %v", err)
+	}
+	defer sender.Close()
+
+	if err := sender.Send(context.Background(), newEntries("hello")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	out := debug.String()
+	if !strings.Contains(out, "/_bulk") || !strings.Contains(out, "hello") {
+		t.Errorf("debug log should contain bulk request and body, got:\n%s", out)
+	}
+}
+
+func TestConfigTransportValidation(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.Transport = http.DefaultTransport
+	config.ESInsecureSkipVerify = true
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for custom transport with tls options")
+	}
+
+	config = elk.DefaultConfig()
+	config.ConnectionTimeout = -time.Second
+	if err := config.Validate(); err == nil {
+		t.Error("expected error for negative connection timeout")
+	}
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+// logDocuments 使用给定配置创建客户端并记录日志，返回写入ES的文档
+func logDocuments(t *testing.T, config *elk.Config, fields ...elk.Fields) ([]map[string]interface{}, elk.MetricsSnapshot) {
+	t.Helper()
+
+	server := elktest.NewServer(t)
+	config.ESAddresses = []string{server.URL}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	for _, f := range fields {
+		if err := client.Info("hello", f); err != nil {
+			t.Fatalf("Info failed: %v", err)
+		}
+	}
+	// Close会先处理队列中剩余的日志
+	_ = client.Close()
+
+	return sources(server), client.GetMetrics()
+}
+
+func TestTypeGuardSchemaCoerce(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{
+		"user_id":  elk.FieldTypeString,
+		"duration": elk.FieldTypeNumber,
+		"ok":       elk.FieldTypeBoolean,
+	}
+
+	docs, metrics := logDocuments(t, config, elk.Fields{
+		"user_id":  42,
+		"duration": "1.5",
+		"ok":       "true",
+	})
+	if len(docs) != 1 {
+		t.Fatalf("got %d documents, want 1", len(docs))
+	}
+
+	doc := docs[0]
+	if doc["user_id"] != "42" {
+		t.Errorf("user_id = %#v, want \"42\"", doc["user_id"])
+	}
+	if doc["duration"] != 1.5 {
+		t.Errorf("duration = %#v, want 1.5", doc["duration"])
+	}
+	if doc["ok"] != true {
+		t.Errorf("ok = %#v, want true", doc["ok"])
+	}
+	if metrics.TypeConflicts != 3 || metrics.TypeConflictFields["user_id"] != 1 {
+		t.Errorf("TypeConflicts = %d, fields = %v", metrics.TypeConflicts, metrics.TypeConflictFields)
+	}
+}
+
+func TestTypeGuardCoerceFallsBackToRename(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{"user_id": elk.FieldTypeNumber}
+
+	docs, _ := logDocuments(t, config, elk.Fields{"user_id": "alice"})
+	doc := docs[0]
+	if _, ok := doc["user_id"]; ok {
+		t.Error("non-numeric user_id should not be written to the number field")
+	}
+	if doc["user_id_str"] != "alice" {
+		t.Errorf("user_id_str = %#v, want alice", doc["user_id_str"])
+	}
+}
+
+func TestTypeGuardInferRename(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.InferFieldTypes = true
+	config.TypeConflictPolicy = elk.TypeConflictRename
+
+	fields := elk.Fields{"user_id": "7"}
+	docs, metrics := logDocuments(t, config,
+		elk.Fields{"user_id": 1},
+		fields,
+		elk.Fields{"user_id": 2},
+	)
+	if len(docs) != 3 {
+		t.Fatalf("got %d documents, want 3", len(docs))
+	}
+
+	var numbers, renamed int
+	for _, doc := range docs {
+		if _, ok := doc["user_id"].(float64); ok {
+			numbers++
+		}
+		if doc["user_id_str"] == "7" {
+			renamed++
+		}
+	}
+	if numbers != 2 || renamed != 1 {
+		t.Errorf("numbers = %d, renamed = %d, documents = %v", numbers, renamed, docs)
+	}
+	if metrics.TypeConflicts != 1 {
+		t.Errorf("TypeConflicts = %d, want 1", metrics.TypeConflicts)
+	}
+	if _, ok := fields["user_id_str"]; ok || fields["user_id"] != "7" {
+		t.Error("caller's fields map should not be modified")
+	}
+}
+
+func TestTypeGuardStringify(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{
+		"request": elk.FieldTypeString,
+		"count":   elk.FieldTypeNumber,
+	}
+	config.TypeConflictPolicy = elk.TypeConflictStringify
+
+	docs, _ := logDocuments(t, config, elk.Fields{
+		"request": map[string]interface{}{"id": 1},
+		"count":   true,
+	})
+	doc := docs[0]
+	if doc["request"] != `{"id":1}` {
+		t.Errorf("request = %#v, want JSON string", doc["request"])
+	}
+	if doc["count_str"] != "true" {
+		t.Errorf("count_str = %#v, want \"true\"", doc["count_str"])
+	}
+}
+
+// codeError 值接收者实现error的结构体，编码为字符串
+type codeError struct {
+	Code int
+}
+
+func (e codeError) Error() string {
+	return fmt.Sprintf("code %d", e.Code)
+}
+
+func TestTypeGuardUsesEncodedType(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{
+		"err":   elk.FieldTypeString,
+		"addr":  elk.FieldTypeString,
+		"cause": elk.FieldTypeString,
+		"meta":  elk.FieldTypeObject,
+		"count": elk.FieldTypeNumber,
+	}
+
+	count := 3
+	docs, metrics := logDocuments(t, config, elk.Fields{
+		"err":   codeError{Code: 7},
+		"addr":  netip.MustParseAddr("10.0.0.1"),
+		"cause": errors.New("timeout"),
+		"meta":  struct{ ID int }{ID: 1},
+		"count": &count,
+	})
+
+	// 类型按编码后的值判断，error和TextMarshaler结构体是字符串，不算冲突
+	if metrics.TypeConflicts != 0 {
+		t.Errorf("TypeConflicts = %d, fields = %v, want 0", metrics.TypeConflicts, metrics.TypeConflictFields)
+	}
+	doc := docs[0]
+	if doc["err"] != "code 7" || doc["addr"] != "10.0.0.1" || doc["cause"] != "timeout" || doc["count"] != float64(3) {
+		t.Errorf("document = %v, want values encoded unchanged", doc)
+	}
+	if lookup(doc, "meta.ID") != float64(1) {
+		t.Errorf("meta = %v, want an object", doc["meta"])
+	}
+}
+
+func TestTypeGuardRenameKeepsExistingField(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{"user_id": elk.FieldTypeNumber}
+	config.TypeConflictPolicy = elk.TypeConflictRename
+
+	docs, _ := logDocuments(t, config, elk.Fields{
+		"user_id":     "alice",
+		"user_id_str": "caller's own field",
+	})
+	doc := docs[0]
+	if doc["user_id_str"] != "caller's own field" {
+		t.Errorf("user_id_str = %#v, should not be overwritten by the renamed field", doc["user_id_str"])
+	}
+	if doc["user_id_str_2"] != "alice" {
+		t.Errorf("user_id_str_2 = %#v, want the renamed value", doc["user_id_str_2"])
+	}
+}
+
+func TestConfigFieldTypesValidation(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldTypes = map[string]elk.FieldType{"user_id": "keyword"}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate should reject unknown field type")
+	}
+
+	config = elk.DefaultConfig()
+	config.TypeConflictPolicy = "drop"
+	if err := config.Validate(); err == nil {
+		t.Error("Validate should reject unknown type conflict policy")
+	}
+}
+
+func TestLoadConfigFieldTypes(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+fields:
+  types:
+    user_id: string
+  infer_types: true
+  type_conflict: rename
+`)
+
+	config, err := elk.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.FieldTypes["user_id"] != elk.FieldTypeString || !config.InferFieldTypes ||
+		config.TypeConflictPolicy != elk.TypeConflictRename {
+		t.Errorf("field types config = %v %v %q", config.FieldTypes, config.InferFieldTypes, config.TypeConflictPolicy)
+	}
+}
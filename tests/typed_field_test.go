@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	elk "github.com/moonlitxy/elk_logger/pkg"
+	"github.com/moonlitxy/elk_logger/pkg/elktest"
+)
+
+func TestTypedFieldsEncoding(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	entry := &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelInfo,
+		Message:   "typed",
+		TypedFields: []elk.Field{
+			elk.String("order_id", "ORD-1"),
+			elk.Int("items", 3),
+			elk.Int64("big", 1<<40),
+			elk.Float64("amount", 9.5),
+			elk.Bool("paid", true),
+			elk.Duration("elapsed", 1500*time.Millisecond),
+			elk.Time("at", at),
+			elk.Err(errors.New("boom")),
+			elk.Any("meta", map[string]int{"a": 1}),
+		},
+	}
+
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	doc := decode(t, data)
+
+	expected := map[string]interface{}{
+		"order_id": "ORD-1",
+		"items":    float64(3),
+		"big":      float64(1 << 40),
+		"amount":   9.5,
+		"paid":     true,
+		"elapsed":  float64(1500 * time.Millisecond),
+		"at":       "2024-01-02T03:04:05.000000006Z",
+		"error":    "boom",
+		"meta.a":   float64(1),
+	}
+	for path, want := range expected {
+		if got := lookup(doc, path); got != want {
+			t.Errorf("%s = %#v, want %#v", path, got, want)
+		}
+	}
+
+	// 与写入Fields的编码结果一致
+	mapEntry := &elk.LogEntry{Timestamp: entry.Timestamp, Level: entry.Level, Message: entry.Message, Fields: entry.AllFields()}
+	mapData, err := mapEntry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if got, want := decode(t, data), decode(t, mapData); len(got) != len(want) {
+		t.Errorf("typed fields = %v, map fields = %v", got, want)
+	}
+}
+
+func TestTypedFieldErrNil(t *testing.T) {
+	entry := &elk.LogEntry{Timestamp: time.Now(), Level: elk.LevelInfo, TypedFields: []elk.Field{elk.Err(nil)}}
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if doc := decode(t, data); doc["error"] != nil {
+		t.Errorf("error = %v, want null", doc["error"])
+	}
+}
+
+func TestTypedFieldsOverrideFields(t *testing.T) {
+	entry := &elk.LogEntry{
+		Timestamp:   time.Now(),
+		Level:       elk.LevelInfo,
+		Fields:      elk.Fields{"user_id": "from map", "other": 1},
+		TypedFields: []elk.Field{elk.String("user_id", "first"), elk.String("user_id", "typed")},
+	}
+	data, err := entry.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	doc := decode(t, data)
+	if doc["user_id"] != "typed" || doc["other"] != float64(1) {
+		t.Errorf("document = %v, want the last typed field to win", doc)
+	}
+}
+
+func TestClientTypedAndMapFields(t *testing.T) {
+	server := elktest.NewServer(t)
+	config := server.NewConfig()
+	config.FieldTypes = map[string]elk.FieldType{"user_id": elk.FieldTypeString}
+
+	client, err := elk.NewClient(config)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	err = client.InfoTyped("order created",
+		elk.Int("user_id", 42),
+		elk.String("message", "user message"),
+	)
+	if err != nil {
+		t.Fatalf("InfoTyped failed: %v", err)
+	}
+
+	// 未命名的map类型变量和nil可以直接作为Fields传入
+	var fields map[string]interface{} = map[string]interface{}{"channel": "app"}
+	if err := client.Info("order paid", fields, nil); err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	// 字段在调用期间已被复制
+	fields["channel"] = "changed"
+	_ = client.Close()
+
+	docs := sources(server)
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2", len(docs))
+	}
+
+	expected := []map[string]interface{}{
+		{"message": "order created", "custom_message": "user message", "user_id": "42"},
+		{"message": "order paid", "channel": "app"},
+	}
+	for i, want := range expected {
+		for key, value := range want {
+			if got := docs[i][key]; got != value {
+				t.Errorf("doc %d %s = %#v, want %#v", i, key, got, value)
+			}
+		}
+	}
+}
+
+func TestTypedFieldsNamespace(t *testing.T) {
+	config := elk.DefaultConfig()
+	config.FieldsNamespace = "labels"
+
+	entry := &elk.LogEntry{
+		Timestamp:   time.Now(),
+		Level:       elk.LevelInfo,
+		Fields:      elk.Fields{"a": 1},
+		TypedFields: []elk.Field{elk.String("b", "x")},
+	}
+	doc := sendDocuments(t, config, entry)[0]
+	if lookup(doc, "labels.a") != float64(1) || lookup(doc, "labels.b") != "x" {
+		t.Errorf("document = %v, want both fields under labels", doc)
+	}
+}
+
+func TestTypedFieldsEncodeWithoutAllocations(t *testing.T) {
+	entry := &elk.LogEntry{
+		Timestamp: time.Now(),
+		Level:     elk.LevelInfo,
+		Message:   "typed",
+		TypedFields: []elk.Field{
+			elk.String("order_id", "ORD-1"),
+			elk.Int("items", 3),
+			elk.Float64("amount", 9.5),
+			elk.Duration("elapsed", time.Second),
+			elk.Time("at", time.Now()),
+		},
+	}
+
+	// 只有返回的字节切片一次分配
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = entry.ToJSON()
+	})
+	if allocs > 1 {
+		t.Errorf("ToJSON allocs = %v, want at most 1", allocs)
+	}
+}
+
+func TestClientTypedFieldsDoNotAllocate(t *testing.T) {
+	// 通道已满且策略为drop_newest时条目创建后直接回收到池中，
+	// 测得的只有记录日志本身的分配，不包含批量发送
+	q := newFullQueueClient(t, elk.PolicyDropNewest)
+	client := q.client
+	_ = client.Info("a")
+	_ = client.Info("b")
+
+	err := errors.New("payment declined")
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = client.InfoTyped("order created",
+			elk.Int("user_id", 12345),
+			elk.String("order_id", "ORD-1"),
+			elk.Duration("elapsed", time.Millisecond),
+			elk.Err(err),
+		)
+	})
+	if allocs != 0 {
+		t.Errorf("InfoTyped allocs = %v, want 0", allocs)
+	}
+}